@@ -100,7 +100,8 @@ func main() {
 	// Create an instance of our custom resource.
 	example := &crv1.Example{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "example1",
+			Name:      "example1",
+			Namespace: apiv1.NamespaceDefault,
 		},
 		Spec: crv1.ExampleSpec{
 			Foo: "hello",
@@ -112,18 +113,11 @@ func main() {
 		},
 	}
 
-	// TODO(CD): Replace this rest client usage with the CRD client wrapper
-	//           when the Create method supports returning the result object.
-	var result crv1.Example
-	err = crdClient.RESTClient().Post().
-		Resource(crv1.ExampleResourcePlural).
-		Namespace(apiv1.NamespaceDefault).
-		Body(example).
-		Do().Into(&result)
+	result, err := crdClient.CreateObject(example)
 	if err == nil {
 		fmt.Printf("CREATED: %#v\n", result)
 	} else if apierrors.IsAlreadyExists(err) {
-		fmt.Printf("ALREADY EXISTS: %#v\n", result)
+		fmt.Printf("ALREADY EXISTS: %#v\n", example)
 	} else {
 		panic(err)
 	}
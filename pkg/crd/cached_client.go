@@ -0,0 +1,96 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package crd
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+)
+
+type cachedClient struct {
+	Client
+	handle        *Handle
+	informerCache *informer.Cache
+}
+
+// NewCachedClient returns a client that serves Get and List from informers
+// shared through the supplied cache. Writes, reads before the informer has
+// synced, and gets of custom resources missing from the cache go to the
+// supplied client. Custom resources read from the
+// cache may be slightly out of date, in which case updates fail with a
+// conflict and should be retried.
+func NewCachedClient(client Client, h *Handle, informerCache *informer.Cache) Client {
+	return &cachedClient{
+		Client:        client,
+		handle:        h,
+		informerCache: informerCache,
+	}
+}
+
+func (c *cachedClient) sharedInformer(namespace string) cache.SharedIndexInformer {
+	lw := cache.NewListWatchFromClient(c.RESTClient(), c.handle.Plural, namespace, fields.Everything())
+	gvr := c.handle.SchemaGroupVersion.WithResource(c.handle.Plural)
+	key := gvr.String() + "/" + namespace
+	return c.informerCache.Informer(key, lw, c.handle.ResourceType)
+}
+
+// WithContext returns a copy of the client whose requests to the API server
+// are bound to the supplied context.
+func (c *cachedClient) WithContext(ctx context.Context) Client {
+	return &cachedClient{
+		Client:        c.Client.WithContext(ctx),
+		handle:        c.handle,
+		informerCache: c.informerCache,
+	}
+}
+
+// Get retrieves the CR from the cache.
+func (c *cachedClient) Get(namespace string, name string) (runtime.Object, error) {
+	sharedInformer := c.sharedInformer(namespace)
+	if !sharedInformer.HasSynced() {
+		return c.Client.Get(namespace, name)
+	}
+	obj, exists, err := informer.Get(sharedInformer, namespace, name)
+	if err != nil {
+		return c.handle.ResourceType.DeepCopyObject(), err
+	}
+	if !exists {
+		// The informer may not have caught up with a custom resource created
+		// recently.
+		return c.Client.Get(namespace, name)
+	}
+	return obj, nil
+}
+
+// List retrieves the list of CRs matching the supplied labels from the
+// cache.
+func (c *cachedClient) List(namespace string, labels map[string]string) (runtime.Object, error) {
+	sharedInformer := c.sharedInformer(namespace)
+	if !sharedInformer.HasSynced() {
+		return c.Client.List(namespace, labels)
+	}
+	result := c.handle.ResourceListType.DeepCopyObject()
+	err := informer.ListInto(sharedInformer, namespace, labels, result)
+	return result, err
+}
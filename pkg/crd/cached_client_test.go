@@ -0,0 +1,64 @@
+//
+// Copyright (c) 2018 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package crd
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+)
+
+func TestCachedClientGetMiss(t *testing.T) {
+	var gets int32
+	watchBody, watchWriter := io.Pipe()
+	defer watchWriter.Close()
+	client := fakeClient(func(request *http.Request) (*http.Response, error) {
+		require.Equal(t, "GET", request.Method)
+		switch {
+		case request.URL.Query().Get("watch") == "true":
+			// Watch without events until the test ends.
+			return &http.Response{StatusCode: 200, Status: "200 OK", Body: watchBody}, nil
+		case request.URL.Path == "/apis/namespaces/test-intel/testcrds":
+			return httpStatus(200, "200 OK", `{"kind":"TestCRDList","apiVersion":"test.intel.com/v1","metadata":{"resourceVersion":"1"},"items":[]}`), nil
+		default:
+			require.Equal(t, "/apis/namespaces/test-intel/testcrds/foobar", request.URL.Path)
+			atomic.AddInt32(&gets, 1)
+			return httpStatus(200, "200 OK", testCRDJSON), nil
+		}
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	cached := NewCachedClient(client, testHandle, informer.NewCache(0, stopCh))
+	sharedInformer := cached.(*cachedClient).sharedInformer("test-intel")
+	require.True(t, cache.WaitForCacheSync(stopCh, sharedInformer.HasSynced))
+
+	// The custom resource is not in the synced cache yet, e.g. because it
+	// was just created, so it is got from the API server.
+	obj, err := cached.Get("test-intel", "foobar")
+	require.Nil(t, err)
+	require.Equal(t, "foobar", obj.(*TestCRD).Name())
+	require.EqualValues(t, 1, atomic.LoadInt32(&gets))
+}
@@ -19,10 +19,10 @@
 package crd
 
 import (
-	"errors"
-	"fmt"
+	"context"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apilabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/rest"
@@ -37,17 +37,24 @@ const apiRoot = "/apis"
 // Client is used to handle CRD operations.
 type Client interface {
 	Create(crd CustomResource) error
+	// CreateObject creates the supplied custom resource and returns it as
+	// stored by the API server, e.g. with its UID and resource version.
+	CreateObject(crd CustomResource) (runtime.Object, error)
 	Get(namespace string, name string) (runtime.Object, error)
 	Update(crd CustomResource) (runtime.Object, error)
 	Delete(namespace string, name string) error
 	Validate(crd CustomResource) error
 	RESTClient() rest.Interface
 	List(namespace string, labels map[string]string) (runtime.Object, error)
+	// WithContext returns a copy of the client whose requests are bound to
+	// the supplied context, e.g. to cancel them or to set a deadline.
+	WithContext(ctx context.Context) Client
 }
 
 type client struct {
 	restClient rest.Interface
 	handle     *Handle
+	ctx        context.Context
 }
 
 // NewClient returns a new REST client wrapper for the supplied CRD handle.
@@ -69,28 +76,59 @@ func NewClient(config rest.Config, h *Handle) (Client, error) {
 		return nil, err
 	}
 
-	return &client{restClient, h}, nil
+	return &client{restClient: restClient, handle: h}, nil
 }
 
 func (c *client) RESTClient() rest.Interface {
 	return c.restClient
 }
 
+// WithContext returns a copy of the client whose requests are bound to the
+// supplied context.
+func (c *client) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	return &clientCopy
+}
+
 // Create creates the supplied CRD.
 func (c *client) Create(crd CustomResource) error {
-	if c.handle.SchemaURL != "" {
-		if err := c.Validate(crd); err != nil {
-			return err
-		}
+	if err := c.validateIfSchema(crd); err != nil {
+		return err
+	}
+	return c.post(crd).Error()
+}
+
+// CreateObject creates the supplied CRD and returns the created object.
+func (c *client) CreateObject(crd CustomResource) (runtime.Object, error) {
+	if err := c.validateIfSchema(crd); err != nil {
+		return nil, err
+	}
+
+	result := c.handle.ResourceType.DeepCopyObject()
+	if err := c.post(crd).Into(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// validateIfSchema validates the supplied CRD if the handle has a schema.
+func (c *client) validateIfSchema(crd CustomResource) error {
+	if c.handle.SchemaURL == "" {
+		return nil
 	}
+	return c.Validate(crd)
+}
 
+// post posts the supplied CRD to the collection of its namespace. The API
+// server rejects creation requests addressed to the object name.
+func (c *client) post(crd CustomResource) rest.Result {
 	return c.restClient.Post().
+		Context(c.ctx).
 		Namespace(crd.Namespace()).
 		Resource(c.handle.Plural).
-		Name(crd.Name()).
 		Body(crd).
-		Do().
-		Error()
+		Do()
 }
 
 // Get retrieves the CRD from the Kubernetes API server.
@@ -99,21 +137,29 @@ func (c *client) Get(namespace string, name string) (runtime.Object, error) {
 	// enable the usage of metav1.GetOptions{}.
 	result := c.handle.ResourceType.DeepCopyObject()
 	err := c.restClient.Get().
+		Context(c.ctx).
 		Namespace(namespace).
 		Resource(c.handle.Plural).
 		Name(name).
 		Do().
 		Into(result)
 
-	return result, err
+	return result, wrapAPIError("get", c.handle.Plural, namespace, name, err)
 }
 
-// List retrieves the list of CRs from the API server
+// List retrieves the list of CRs matching the supplied labels from the API
+// server.
 func (c *client) List(namespace string, labels map[string]string) (runtime.Object, error) {
 	result := c.handle.ResourceListType.DeepCopyObject()
-	err := c.restClient.Get().
+	request := c.restClient.Get().
+		Context(c.ctx).
 		Namespace(namespace).
-		Resource(c.handle.Plural).
+		Resource(c.handle.Plural)
+	if len(labels) > 0 {
+		selector := apilabels.SelectorFromSet(apilabels.Set(labels))
+		request = request.Param("labelSelector", selector.String())
+	}
+	err := request.
 		Do().
 		Into(result)
 
@@ -129,6 +175,7 @@ func (c *client) Update(crd CustomResource) (runtime.Object, error) {
 	}
 
 	resp := c.restClient.Put().
+		Context(c.ctx).
 		Namespace(crd.Namespace()).
 		Resource(c.handle.Plural).
 		Name(crd.Name()).
@@ -137,27 +184,29 @@ func (c *client) Update(crd CustomResource) (runtime.Object, error) {
 
 	obj, err := resp.Get()
 	if err != nil {
-		return nil, err
+		return nil, wrapAPIError("update", c.handle.Plural, crd.Namespace(), crd.Name(), err)
 	}
 
-	return obj, resp.Error()
+	return obj, wrapAPIError("update", c.handle.Plural, crd.Namespace(), crd.Name(), resp.Error())
 }
 
 // Delete deletes the CRD from the Kubernetes API server.
 func (c *client) Delete(namespace string, name string) error {
-	return c.restClient.Delete().
+	err := c.restClient.Delete().
+		Context(c.ctx).
 		Namespace(namespace).
 		Resource(c.handle.Plural).
 		Name(name).
 		Do().
 		Error()
+	return wrapAPIError("delete", c.handle.Plural, namespace, name, err)
 }
 
 // Validate validates a custom resource against a json schema.
 // Returns nil if object adheres to the schema.
 func (c *client) Validate(cr CustomResource) error {
 	if c.handle.SchemaURL == "" {
-		return fmt.Errorf("Validate called without schema URL set")
+		return ErrNoSchema
 	}
 
 	schemaLoader := gojsonschema.NewReferenceLoader(c.handle.SchemaURL)
@@ -174,13 +223,14 @@ func (c *client) Validate(cr CustomResource) error {
 	}
 
 	if !result.Valid() {
-		errorOutput := "Invalid JSON: '" + json + "': "
-
+		validationErr := &ValidationError{JSON: json}
 		for _, desc := range result.Errors() {
-			errorOutput = errorOutput + " - " + desc.String() + "\n"
+			validationErr.Fields = append(validationErr.Fields, FieldError{
+				Field:       desc.Field(),
+				Description: desc.Description(),
+			})
 		}
-
-		return errors.New(errorOutput)
+		return validationErr
 	}
 
 	return nil
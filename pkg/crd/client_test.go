@@ -26,6 +26,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 	"github.com/stretchr/testify/require"
 	extv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
@@ -43,6 +44,8 @@ import (
 const (
 	testCRDJSON = `{"kind":"TestCRD","apiVersion":"test.intel.com/v1","metadata":{"name":"foobar","namespace":"test-intel","creationTimestamp":null}}
 `
+	testCRDListJSON = `{"kind":"TestCRDList","apiVersion":"test.intel.com/v1","metadata":{},"items":[` +
+		`{"kind":"TestCRD","apiVersion":"test.intel.com/v1","metadata":{"name":"foobar","namespace":"test-intel","creationTimestamp":null}}]}`
 )
 
 var (
@@ -94,6 +97,8 @@ func TestNewClient(t *testing.T) {
 func TestCreateOK(t *testing.T) {
 	client := fakeClient(func(request *http.Request) (*http.Response, error) {
 		require.Equal(t, "POST", request.Method)
+		// New objects are posted to the collection, not to their name.
+		require.Equal(t, "/apis/namespaces/test-intel/testcrds", request.URL.Path)
 
 		require.Equal(t,
 			testCRDJSON,
@@ -106,6 +111,24 @@ func TestCreateOK(t *testing.T) {
 	require.Nil(t, err)
 }
 
+func TestCreateObjectOK(t *testing.T) {
+	client := fakeClient(func(request *http.Request) (*http.Response, error) {
+		require.Equal(t, "POST", request.Method)
+		require.Equal(t, "/apis/namespaces/test-intel/testcrds", request.URL.Path)
+		require.Equal(t, testCRDJSON, readBody(t, request.Body))
+
+		return httpStatus(201, "201 Created", testCRDJSON), nil
+	})
+
+	crd, err := client.CreateObject(testCRD)
+	require.Nil(t, err)
+
+	b, ok := crd.(*TestCRD)
+	require.True(t, ok)
+	require.Equal(t, b.Name(), "foobar")
+	require.Equal(t, b.Namespace(), "test-intel")
+}
+
 func TestCreateSchemaFail(t *testing.T) {
 	client := fakeClient(func(request *http.Request) (*http.Response, error) {
 		require.Fail(t, "Request should not make it to the API server")
@@ -120,6 +143,10 @@ func TestCreateSchemaFail(t *testing.T) {
 		},
 	})
 	require.Contains(t, err.Error(), "Invalid JSON")
+
+	validationErr, ok := err.(*ValidationError)
+	require.True(t, ok)
+	require.NotEmpty(t, validationErr.FieldPaths())
 }
 
 func TestCreateFail(t *testing.T) {
@@ -157,9 +184,11 @@ func TestGetNotFound(t *testing.T) {
 
 	_, err := client.Get("test-intel", "foobar")
 	require.NotNil(t, err)
-	statusError, ok := err.(*k8serror.StatusError)
+	apiError, ok := err.(*resource.APIError)
 	require.True(t, ok)
-	require.EqualValues(t, statusError.ErrStatus.Reason, "NotFound")
+	require.EqualValues(t, apiError.Status().Reason, "NotFound")
+	require.True(t, k8serror.IsNotFound(err))
+	require.True(t, resource.IsNotFound(err))
 }
 
 func TestGetFail(t *testing.T) {
@@ -218,6 +247,47 @@ func TestUpdateNotFound(t *testing.T) {
 
 	_, err := client.Update(testCRD)
 	require.NotNil(t, err)
+	require.True(t, resource.IsNotFound(err))
+}
+
+func TestUpdateConflict(t *testing.T) {
+	client := fakeClient(func(request *http.Request) (*http.Response, error) {
+		require.Equal(t, "PUT", request.Method)
+		return httpStatus(409, "409 Conflict", ""), nil
+	})
+
+	_, err := client.Update(testCRD)
+	require.NotNil(t, err)
+	_, ok := err.(*resource.APIError)
+	require.True(t, ok)
+	require.True(t, resource.IsConflict(err))
+}
+
+func TestListWithLabels(t *testing.T) {
+	client := fakeClient(func(request *http.Request) (*http.Response, error) {
+		require.Equal(t, "GET", request.Method)
+		require.Equal(t, "controller=canary", request.URL.Query().Get("labelSelector"))
+		return httpStatus(200, "200 OK", testCRDListJSON), nil
+	})
+
+	list, err := client.List("test-intel", map[string]string{"controller": "canary"})
+	require.Nil(t, err)
+
+	l, ok := list.(*TestCRDList)
+	require.True(t, ok)
+	require.Len(t, l.Items, 1)
+	require.Equal(t, l.Items[0].Name(), "foobar")
+}
+
+func TestListWithoutLabels(t *testing.T) {
+	client := fakeClient(func(request *http.Request) (*http.Response, error) {
+		require.Equal(t, "GET", request.Method)
+		require.Empty(t, request.URL.Query().Get("labelSelector"))
+		return httpStatus(200, "200 OK", testCRDListJSON), nil
+	})
+
+	_, err := client.List("test-intel", map[string]string{})
+	require.Nil(t, err)
 }
 
 // Reads from reader and returns string.
@@ -257,14 +327,14 @@ func fakeClient(handler func(request *http.Request) (*http.Response, error)) *cl
 	})
 
 	return &client{
-		&fake.RESTClient{
+		restClient: &fake.RESTClient{
 			GroupName:            "test.intel.com",
 			VersionedAPIPath:     "/apis",
 			Client:               fake.CreateHTTPClient(handler),
 			NegotiatedSerializer: serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)},
 			APIRegistry:          apiRegistry,
 		},
-		testHandle,
+		handle: testHandle,
 	}
 }
 
@@ -0,0 +1,86 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package crd
+
+import (
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource"
+)
+
+// ErrNoSchema is returned when validating custom resources of a handle
+// without a schema URL.
+var ErrNoSchema = errors.New("Validate called without schema URL set")
+
+// FieldError describes a custom resource field that does not adhere to the
+// schema.
+type FieldError struct {
+	// Field is the path of the field, e.g. "spec.replicas", or "(root)".
+	Field       string
+	Description string
+}
+
+// ValidationError reports that a custom resource does not adhere to the
+// schema of its handle. API server errors for missing objects and conflicts
+// are returned as *resource.APIError, like the errors of resource clients.
+// Other API server errors are returned unwrapped as *apierrors.StatusError.
+type ValidationError struct {
+	// JSON is the validated custom resource.
+	JSON   string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	errorOutput := "Invalid JSON: '" + e.JSON + "': "
+	for _, field := range e.Fields {
+		errorOutput = errorOutput + fmt.Sprintf(" - %s: %s\n", field.Field, field.Description)
+	}
+	return errorOutput
+}
+
+// FieldPaths returns the paths of the fields that do not adhere to the
+// schema.
+func (e *ValidationError) FieldPaths() []string {
+	paths := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		paths = append(paths, field.Field)
+	}
+	return paths
+}
+
+// wrapAPIError returns the supplied request error wrapped in a
+// resource.APIError if it is a not-found or conflict error, so that
+// resource.IsNotFound and resource.IsConflict, or errors.Is with
+// resource.ErrNotFound and resource.ErrConflict, treat the errors of custom
+// resource and resource clients alike. Other errors are returned unchanged.
+func wrapAPIError(verb string, plural string, namespace string, name string, err error) error {
+	if !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return err
+	}
+	return &resource.APIError{
+		Verb:      verb,
+		Plural:    plural,
+		Namespace: namespace,
+		Name:      name,
+		Err:       err,
+	}
+}
@@ -19,6 +19,7 @@
 package fake
 
 import (
+	"context"
 	"fmt"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -40,6 +41,11 @@ func (c *ClientImpl) RESTClient() rest.Interface {
 	return nil
 }
 
+// WithContext returns the fake client itself.
+func (c *ClientImpl) WithContext(ctx context.Context) crd.Client {
+	return c
+}
+
 // Create creates the supplied CRD.
 func (c *ClientImpl) Create(cr crd.CustomResource) (e error) {
 	if c.Error != "" {
@@ -48,6 +54,16 @@ func (c *ClientImpl) Create(cr crd.CustomResource) (e error) {
 	return
 }
 
+// CreateObject creates the supplied CRD and returns it.
+func (c *ClientImpl) CreateObject(cr crd.CustomResource) (result runtime.Object, e error) {
+	if c.Error != "" {
+		e = fmt.Errorf(c.Error)
+		return
+	}
+	result = cr
+	return
+}
+
 // Get retrieves the CRD from the Kubernetes API server.
 func (c *ClientImpl) Get(namespace string, name string) (result runtime.Object, e error) {
 	if c.Error != "" {
@@ -0,0 +1,110 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+// Package informer shares informers between clients that serve reads from a
+// local cache.
+package informer
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	apilabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Cache holds one shared informer per kind of object and namespace. The
+// informers are started on first use and stopped when the stop channel
+// supplied to NewCache is closed.
+type Cache struct {
+	resyncPeriod time.Duration
+	stopCh       <-chan struct{}
+
+	mu        sync.Mutex
+	informers map[string]cache.SharedIndexInformer
+}
+
+// NewCache returns a new informer cache.
+func NewCache(resyncPeriod time.Duration, stopCh <-chan struct{}) *Cache {
+	return &Cache{
+		resyncPeriod: resyncPeriod,
+		stopCh:       stopCh,
+		informers:    map[string]cache.SharedIndexInformer{},
+	}
+}
+
+// Informer returns the informer for the supplied key, which identifies the
+// kind of object and the namespace. If there is none yet, a new informer is
+// created from the supplied list watcher and started.
+func (c *Cache) Informer(key string, lw cache.ListerWatcher, objType runtime.Object) cache.SharedIndexInformer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if informer, ok := c.informers[key]; ok {
+		return informer
+	}
+	informer := cache.NewSharedIndexInformer(lw, objType, c.resyncPeriod, cache.Indexers{
+		cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+	})
+	c.informers[key] = informer
+	go informer.Run(c.stopCh)
+	return informer
+}
+
+// Get returns a copy of the cached object with the supplied namespace and
+// name, and false if there is no such object.
+func Get(informer cache.SharedIndexInformer, namespace string, name string) (runtime.Object, bool, error) {
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	item, exists, err := informer.GetIndexer().GetByKey(key)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	obj, ok := item.(runtime.Object)
+	if !ok {
+		return nil, false, nil
+	}
+	// Objects in the cache are shared, so callers get their own copy.
+	return obj.DeepCopyObject(), true, nil
+}
+
+// List returns copies of the cached objects in the supplied namespace that
+// have the supplied labels.
+func List(informer cache.SharedIndexInformer, namespace string, labels map[string]string) ([]runtime.Object, error) {
+	var result []runtime.Object
+	err := cache.ListAllByNamespace(informer.GetIndexer(), namespace, apilabels.SelectorFromSet(apilabels.Set(labels)), func(item interface{}) {
+		if obj, ok := item.(runtime.Object); ok {
+			result = append(result, obj.DeepCopyObject())
+		}
+	})
+	return result, err
+}
+
+// ListInto sets the items of the supplied list object to copies of the
+// cached objects in the supplied namespace that have the supplied labels.
+func ListInto(informer cache.SharedIndexInformer, namespace string, labels map[string]string, list runtime.Object) error {
+	items, err := List(informer, namespace, labels)
+	if err != nil {
+		return err
+	}
+	return meta.SetList(list, items)
+}
@@ -0,0 +1,83 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package informer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	fcache "k8s.io/client-go/tools/cache/testing"
+)
+
+func newPod(namespace, name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+			Labels:    labels,
+		},
+	}
+}
+
+func TestCache(t *testing.T) {
+	source := fcache.NewFakeControllerSource()
+	source.Add(newPod("namespace1", "pod1", map[string]string{"app": "a"}))
+	source.Add(newPod("namespace1", "pod2", map[string]string{"app": "b"}))
+	source.Add(newPod("namespace2", "pod3", map[string]string{"app": "a"}))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informerCache := NewCache(0, stopCh)
+
+	informer := informerCache.Informer("pods", source, &corev1.Pod{})
+	assert.Equal(t, informer, informerCache.Informer("pods", source, &corev1.Pod{}))
+	assert.Nil(t, wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return informer.HasSynced(), nil
+	}))
+
+	obj, exists, err := Get(informer, "namespace1", "pod1")
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, "pod1", obj.(*corev1.Pod).Name)
+
+	// Callers get a copy of the cached object.
+	obj.(*corev1.Pod).Labels["app"] = "c"
+	cached, _, _ := Get(informer, "namespace1", "pod1")
+	assert.Equal(t, "a", cached.(*corev1.Pod).Labels["app"])
+
+	_, exists, err = Get(informer, "namespace1", "pod3")
+	assert.Nil(t, err)
+	assert.False(t, exists)
+
+	objs, err := List(informer, "namespace1", map[string]string{"app": "a"})
+	assert.Nil(t, err)
+	assert.Len(t, objs, 1)
+
+	objs, err = List(informer, "namespace1", nil)
+	assert.Nil(t, err)
+	assert.Len(t, objs, 2)
+
+	list := &corev1.PodList{}
+	assert.Nil(t, ListInto(informer, metav1.NamespaceAll, map[string]string{"app": "a"}, list))
+	assert.Len(t, list.Items, 2)
+}
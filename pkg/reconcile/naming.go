@@ -0,0 +1,112 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package reconcile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"text/template"
+
+	"github.com/golang/glog"
+)
+
+// maxNameLength is the maximum length of the subresource names chosen by
+// HashedName, which keeps them valid DNS labels.
+const maxNameLength = 63
+
+// NamingStrategy chooses the name of the subresource that a resource client
+// manages for a custom resource. The reconciler uses it to create, get and
+// delete the subresource.
+type NamingStrategy interface {
+	// SubresourceName returns the name of the subresource controlled by the
+	// custom resource with the supplied name, or an empty name if the
+	// subresource can't be named. The reconciler skips subresources without
+	// a name.
+	SubresourceName(crName string) string
+}
+
+// NamingFunc adapts a function to the NamingStrategy interface.
+type NamingFunc func(crName string) string
+
+// SubresourceName returns f(crName).
+func (f NamingFunc) SubresourceName(crName string) string {
+	return f(crName)
+}
+
+// CustomResourceName names subresources after their custom resource. It is
+// the strategy of registrations that do not declare one.
+var CustomResourceName NamingStrategy = NamingFunc(func(crName string) string {
+	return crName
+})
+
+// SuffixName names subresources "<cr>-<suffix>", e.g. to tell apart the
+// subresources of several clients for the same kind.
+func SuffixName(suffix string) NamingStrategy {
+	return NamingFunc(func(crName string) string {
+		return crName + "-" + suffix
+	})
+}
+
+// HashedName names subresources like SuffixName, but replaces the tail of
+// names longer than 63 characters with a hash of the full name, so that
+// long custom resource names still yield valid and distinct names.
+func HashedName(suffix string) NamingStrategy {
+	return NamingFunc(func(crName string) string {
+		name := crName + "-" + suffix
+		if len(name) <= maxNameLength {
+			return name
+		}
+		sum := sha256.Sum256([]byte(name))
+		hash := hex.EncodeToString(sum[:])[:10]
+		return name[:maxNameLength-len(hash)-1] + "-" + hash
+	})
+}
+
+// TemplateName names subresources by expanding the supplied template, e.g.
+// "{{.Name}}-worker", given the custom resource name as .Name. It should
+// agree with the name set in the subresource template. An error is returned
+// if the template does not parse, or does not execute for a sample name.
+// Should it fail to execute later on, the error is logged and the name is
+// empty, so that the subresource is skipped rather than named after its
+// custom resource like those of other registrations.
+func TemplateName(text string) (NamingStrategy, error) {
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	execute := func(crName string) (string, error) {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, struct{ Name string }{crName}); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	if _, err := execute("name"); err != nil {
+		return nil, err
+	}
+	return NamingFunc(func(crName string) string {
+		name, err := execute(crName)
+		if err != nil {
+			glog.Errorf(`[reconcile] failed to name subresource of "%s" from template "%s": %v`, crName, text, err)
+			return ""
+		}
+		return name
+	}), nil
+}
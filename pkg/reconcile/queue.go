@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package reconcile
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/crd"
+)
+
+// ResyncCustomResource is implemented by custom resources that declare how
+// often they are reconciled. The period may depend on the custom resource
+// state, e.g. to check pending custom resources more often.
+type ResyncCustomResource interface {
+	// GetResyncPeriod returns the delay between two reconciliations of the
+	// custom resource. Zero means the reconciler default.
+	GetResyncPeriod() time.Duration
+}
+
+// EnqueueAfter schedules the custom resource with the supplied name to be
+// reconciled after the supplied delay. It may be called from controller
+// hooks to reconcile a custom resource sooner than its resync period.
+func (r *Reconciler) EnqueueAfter(crName string, after time.Duration) {
+	r.scheduledMu.Lock()
+	r.scheduled[crName] = struct{}{}
+	r.scheduledMu.Unlock()
+	r.queue.AddAfter(crName, after)
+}
+
+// discover schedules every custom resource, and every controller of orphaned
+// subresources, that is not scheduled yet.
+func (r *Reconciler) discover() {
+	pass, cancel := r.withTimeout()
+	defer cancel()
+	subresourcesByCR := pass.groupSubresourcesByCustomResource()
+	r.listed.set(subresourcesByCR)
+
+	r.scheduledMu.Lock()
+	defer r.scheduledMu.Unlock()
+	for crName := range subresourcesByCR {
+		if _, ok := r.scheduled[crName]; ok {
+			continue
+		}
+		r.scheduled[crName] = struct{}{}
+		r.queue.Add(crName)
+	}
+}
+
+// forget stops scheduling the custom resource with the supplied name until
+// it is discovered again.
+func (r *Reconciler) forget(crName string) {
+	r.scheduledMu.Lock()
+	defer r.scheduledMu.Unlock()
+	delete(r.scheduled, crName)
+}
+
+func (r *Reconciler) worker() {
+	for r.processNextItem() {
+	}
+}
+
+func (r *Reconciler) processNextItem() bool {
+	item, shutdown := r.queue.Get()
+	if shutdown {
+		return false
+	}
+	defer r.queue.Done(item)
+
+	crName := item.(string)
+	requeueAfter, ok := r.reconcile(crName)
+	if !ok {
+		r.forget(crName)
+		return true
+	}
+	r.queue.AddAfter(crName, requeueAfter)
+	return true
+}
+
+// reconcile plans and executes the action for the custom resource with the
+// supplied name. It returns the delay before the custom resource should be
+// reconciled again, and false if there is nothing left to reconcile. The
+// requests made on the way are bounded by the reconciler timeout.
+func (r *Reconciler) reconcile(crName string) (time.Duration, bool) {
+	pass, cancel := r.withTimeout()
+	defer cancel()
+	return pass.reconcilePass(crName)
+}
+
+func (r *Reconciler) reconcilePass(crName string) (time.Duration, bool) {
+	subs := r.subresourcesFor(crName)
+	a, cr, err := r.planAction(crName, subs)
+	if err != nil {
+		glog.Errorf(`failed to plan action for custom resource: [%s] subresources: [%v] error: [%s]`, crName, subs, err.Error())
+		return r.interval, true
+	}
+	// Stop scheduling custom resources that are gone, or that another
+	// reconciler is responsible for, once there is nothing left to delete.
+	if cr == nil && len(a.subresourcesToDelete) == 0 {
+		return 0, false
+	}
+	if cr != nil {
+		a.subresourceStatuses = r.subresourceStatuses(subs)
+	}
+	glog.Infof("planned action: %s", a.String())
+	errs := r.executeAction(crName, cr, a)
+	if len(errs) > 0 {
+		glog.Errorf(`failed to execute action for custom resource: [%s] subresources: %v errors: %v`, crName, subs, errs)
+	}
+	return r.requeueAfter(cr, a), true
+}
+
+// requeueAfter returns the delay before the supplied custom resource is
+// reconciled again.
+func (r *Reconciler) requeueAfter(cr crd.CustomResource, a *action) time.Duration {
+	if a.requeueAfter > 0 {
+		return a.requeueAfter
+	}
+	if rcr, ok := cr.(ResyncCustomResource); ok {
+		if period := rcr.GetResyncPeriod(); period > 0 {
+			return period
+		}
+	}
+	return r.interval
+}
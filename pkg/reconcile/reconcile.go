@@ -20,8 +20,11 @@ package reconcile
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -29,7 +32,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
 
 	"github.com/golang/glog"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/crd"
@@ -45,38 +50,132 @@ import (
 // See the docs/reconciliation.md file for a detailed description of the
 // reconciliation policy.
 type Reconciler struct {
-	namespace       string
-	gvk             schema.GroupVersionKind
-	crdHandle       *crd.Handle
-	crdClient       crd.Client
-	resourceClients []resource.Client
+	namespace         string
+	gvk               schema.GroupVersionKind
+	crdHandle         *crd.Handle
+	crdClient         crd.Client
+	registrations     map[string]Registration
+	registrationNames []string
+	options           Options
+	interval          time.Duration
+	ctx               context.Context
+	listed            *listing
+	queue             workqueue.DelayingInterface
+	scheduledMu       sync.Mutex
+	scheduled         map[string]struct{}
 }
 
 // New returns a new Reconciler.
 func New(namespace string, gvk schema.GroupVersionKind, crdHandle *crd.Handle, crdClient crd.Client, resourceClients []resource.Client) *Reconciler {
-	return &Reconciler{
-		namespace:       namespace,
-		gvk:             gvk,
-		crdHandle:       crdHandle,
-		crdClient:       crdClient,
-		resourceClients: resourceClients,
+	return NewWithRegistrations(namespace, gvk, crdHandle, crdClient, registrationsFor(resourceClients))
+}
+
+// NewWithRegistrations returns a new Reconciler that applies the policy in
+// each registration to the subresources managed by its client.
+func NewWithRegistrations(namespace string, gvk schema.GroupVersionKind, crdHandle *crd.Handle, crdClient crd.Client, registrations []Registration) *Reconciler {
+	return NewWithOptions(namespace, gvk, crdHandle, crdClient, registrations, Options{})
+}
+
+// NewWithOptions returns a new Reconciler that is responsible only for the
+// custom resources selected by the supplied options. Registrations whose
+// name is already taken are ignored.
+func NewWithOptions(namespace string, gvk schema.GroupVersionKind, crdHandle *crd.Handle, crdClient crd.Client, registrations []Registration, options Options) *Reconciler {
+	r := &Reconciler{
+		namespace:     namespace,
+		gvk:           gvk,
+		crdHandle:     crdHandle,
+		crdClient:     crdClient,
+		registrations: map[string]Registration{},
+		options:       options,
+		listed:        &listing{},
+		queue:         workqueue.NewNamedDelayingQueue(gvk.Kind),
+		scheduled:     map[string]struct{}{},
 	}
+	for _, registration := range registrations {
+		name := registration.name()
+		if _, ok := r.registrations[name]; ok {
+			glog.Errorf(`[reconcile] ignoring duplicate registration "%s"`, name)
+			continue
+		}
+		r.registrations[name] = registration
+		r.registrationNames = append(r.registrationNames, name)
+	}
+	return r
 }
 
 // Run starts the reconciliation loop and blocks until the context is done, or
-// there is an unrecoverable error. Reconciliation actions are done at the
-// supplied interval.
+// there is an unrecoverable error. Cancelling the context also cancels the
+// requests in flight. Custom resources are discovered at the
+// supplied interval. Each custom resource is then reconciled after the delay
+// requested by the planner, at the resync period it declares, or at the
+// supplied interval otherwise.
 func (r *Reconciler) Run(ctx context.Context, interval time.Duration) error {
 	glog.V(4).Infof("Starting reconciler for %v.%v.%v", r.gvk.Group, r.gvk.Version, r.gvk.Kind)
-	go wait.Until(r.run, interval, ctx.Done())
+	r.interval = interval
+	r.ctx = ctx
+	go wait.Until(r.discover, interval, ctx.Done())
+	go wait.Until(r.worker, time.Second, ctx.Done())
 	<-ctx.Done()
+	r.queue.ShutDown()
 	return ctx.Err()
 }
 
+// defaultTimeout bounds a reconcile pass when Options.Timeout is zero.
+const defaultTimeout = time.Minute
+
+// withTimeout returns a copy of the reconciler whose clients are bound to a
+// context derived from the one supplied to Run, and a function that
+// releases the context once the pass is over. The copy neither schedules
+// nor forgets custom resources.
+func (r *Reconciler) withTimeout() (*Reconciler, context.CancelFunc) {
+	parent := r.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	timeout := r.options.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(parent, timeout)
+
+	pass := &Reconciler{
+		namespace:         r.namespace,
+		gvk:               r.gvk,
+		crdHandle:         r.crdHandle,
+		crdClient:         r.crdClient.WithContext(ctx),
+		registrations:     map[string]Registration{},
+		registrationNames: r.registrationNames,
+		options:           r.options,
+		interval:          r.interval,
+		ctx:               ctx,
+		listed:            r.listed,
+	}
+	for name, registration := range r.registrations {
+		registration.Client = registration.Client.WithContext(ctx)
+		pass.registrations[name] = registration
+	}
+	return pass, cancel
+}
+
 type subresource struct {
 	client    resource.Client
 	object    runtime.Object
 	lifecycle lifecycle
+	// registration is the name of the registration of the client. Empty
+	// means the plural form of the client.
+	registration string
+	// name is the name of the object, or the name it is created with if it
+	// does not exist.
+	name string
+}
+
+// registrationName returns the name of the registration that manages the
+// subresource.
+func (s *subresource) registrationName() string {
+	if s.registration != "" {
+		return s.registration
+	}
+	return s.client.Plural()
 }
 
 type subresources []*subresource
@@ -88,7 +187,11 @@ type action struct {
 	newCRState           states.State
 	newCRReason          string
 	subresourcesToCreate subresources
+	subresourcesToUpdate subresources
 	subresourcesToDelete subresources
+	subresourceStatuses  []SubresourceStatus
+	restart              *restartStatus
+	requeueAfter         time.Duration
 }
 
 func (a action) String() string {
@@ -96,6 +199,10 @@ func (a action) String() string {
 	for _, s := range a.subresourcesToCreate {
 		sCreateNames = append(sCreateNames, s.client.Plural())
 	}
+	var sUpdateNames []string
+	for _, s := range a.subresourcesToUpdate {
+		sUpdateNames = append(sUpdateNames, s.client.Plural())
+	}
 	var sDeleteNames []string
 	for _, s := range a.subresourcesToDelete {
 		sDeleteNames = append(sDeleteNames, s.client.Plural())
@@ -105,30 +212,16 @@ func (a action) String() string {
   newCRState: "%s",
   newCRReason: "%s",
   subresourcesToCreate: "%s",
+  subresourcesToUpdate: "%s",
   subresourcesToDelete: "%s"
 }`,
 		a.newCRState,
 		a.newCRReason,
 		strings.Join(sCreateNames, ", "),
+		strings.Join(sUpdateNames, ", "),
 		strings.Join(sDeleteNames, ", "))
 }
 
-func (r *Reconciler) run() {
-	subresourcesByCR := r.groupSubresourcesByCustomResource()
-	for crName, subs := range subresourcesByCR {
-		a, cr, err := r.planAction(crName, subs)
-		if err != nil {
-			glog.Errorf(`failed to plan action for custom resource: [%s] subresources: [%v] error: [%s]`, crName, subresourcesByCR, err.Error())
-			continue
-		}
-		glog.Infof("planned action: %s", a.String())
-		errs := r.executeAction(crName, cr, a)
-		if len(errs) > 0 {
-			glog.Errorf(`failed to execute action for custom resource: [%s] subresources: %v errors: %v`, crName, subresourcesByCR, errs)
-		}
-	}
-}
-
 // TODO(CD): groupSubresourcesByCustomResource() doesn't work for a custom
 // resource with no sub-resource(s) or the sub-resource have been deleted.
 // As resourceClient.List() will not have any sub-resource belonging to the
@@ -142,65 +235,203 @@ func (r *Reconciler) groupSubresourcesByCustomResource() subresourceMap {
 	result := subresourceMap{}
 
 	// Get the list of crs.
-	crListObj, err := r.crdClient.List(r.namespace, map[string]string{})
+	crListObj, err := r.crdClient.List(r.namespace, r.options.Labels)
 	if err != nil || crListObj == nil {
 		glog.Warningf("[reconcile] could not list custom resources. Got error %v %v", err, crListObj)
 		return result
 	}
 	customResourceList := crListObj.(crd.CustomResourceList)
 
-	// Get the list of custom resources
-	crList := customResourceList.GetItems()
+	// Get the list of custom resources this reconciler is responsible for.
+	var crList []runtime.Object
+	for _, item := range customResourceList.GetItems() {
+		if cr, ok := item.(crd.CustomResource); ok && !r.claims(cr) {
+			continue
+		}
+		crList = append(crList, item)
+	}
 	// Return if the list is empty
 	if len(crList) == 0 {
 		glog.Warningf("[reconcile] custom resources list is empty")
 		return result
 	}
 
-	for _, resourceClient := range r.resourceClients {
+	result = r.listSubresources()
+	r.addMissingSubresources(result, crList)
+
+	return result
+}
+
+// subresourcesFor returns the subresources controlled by the custom resource
+// with the supplied name, including those that do not exist yet. The
+// subresources found by the last discovery are got again by name, rather
+// than listing each kind for every custom resource. Custom resources that
+// were not discovered yet have their subresources listed instead.
+func (r *Reconciler) subresourcesFor(crName string) subresources {
+	var subs subresources
+	if listed, ok := r.listed.get(crName); ok {
+		for _, sub := range listed {
+			if sub.lifecycle == doesNotExist {
+				continue
+			}
+			registration, ok := r.registrations[sub.registrationName()]
+			if !ok {
+				continue
+			}
+			if current, ok := r.getSubresource(registration, crName, sub.name); ok {
+				subs = append(subs, current)
+			}
+		}
+	} else {
+		subs = r.listSubresources()[crName]
+	}
+	result := subresourceMap{crName: subs}
+
+	crObj, err := r.crdClient.Get(r.namespace, crName)
+	if err == nil && crObj != nil {
+		r.addMissingSubresources(result, []runtime.Object{crObj})
+	}
+
+	return result[crName]
+}
+
+// listing holds the subresources found by the last discovery, grouped by the
+// name of their controlling custom resource.
+type listing struct {
+	mutex        sync.Mutex
+	subresources subresourceMap
+}
+
+// set replaces the subresources found by the last discovery.
+func (l *listing) set(subresourcesByCR subresourceMap) {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.subresources = subresourcesByCR
+}
+
+// get returns the subresources that the last discovery found for the custom
+// resource with the supplied name, and false if it was not discovered.
+func (l *listing) get(crName string) (subresources, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	subs, ok := l.subresources[crName]
+	return subs, ok
+}
+
+// listSubresources returns the existing subresources grouped by the name of
+// their controlling custom resource. Each kind is listed once, and the listed
+// objects are matched to the registrations for that kind by name.
+func (r *Reconciler) listSubresources() subresourceMap {
+	result := subresourceMap{}
+
+	listed := map[string]bool{}
+	for _, registrationName := range r.registrationNames {
+		resourceClient := r.registrations[registrationName].Client
+		plural := resourceClient.Plural()
+		if listed[plural] {
+			continue
+		}
+		listed[plural] = true
+
 		objects, err := resourceClient.List(r.namespace, map[string]string{})
 		if err != nil {
-			glog.Warningf(`[reconcile] failed to list "%s" subresources`, resourceClient.Plural())
+			glog.Warningf(`[reconcile] failed to list "%s" subresources`, plural)
 			continue
 		}
 
 		for _, obj := range objects {
-			controllerRef := metav1.GetControllerOf(obj)
-			if controllerRef == nil {
-				glog.V(4).Infof("[reconcile] ignoring sub-resource %v, %v as it doesn not have a controller reference", obj.GetName(), r.namespace)
+			controllerName, ok := r.controllerName(obj)
+			if !ok {
 				continue
 			}
-			// Only manipulate controller-created subresources.
-			if controllerRef.APIVersion != r.gvk.GroupVersion().String() || controllerRef.Kind != r.gvk.Kind {
-				glog.V(4).Infof("[reconcile] ignoring sub-resource %v, %v as controlling custom resource is from a different group, version and kind", obj.GetName(), r.namespace)
+			registration, ok := r.registrationFor(plural, controllerName, obj.GetName())
+			if !ok {
+				glog.V(4).Infof(`[reconcile] ignoring sub-resource %v, %v as no "%s" registration names it`, obj.GetName(), r.namespace, plural)
 				continue
 			}
 
 			subLifecycle := exists
-			objMeta, err := meta.Accessor(obj)
-			if err != nil {
-				glog.Warningf("[reconcile] error getting meta accessor for subresource: %v", err)
-				continue
-			}
-			if objMeta.GetDeletionTimestamp() != nil {
+			if obj.GetDeletionTimestamp() != nil {
 				subLifecycle = deleting
 			}
 
 			runtimeObj, ok := obj.(runtime.Object)
 			if !ok {
-				glog.Warningf("[reconcile] error asserting metav1.Object as runtime.Object: %v", err)
+				glog.Warningf("[reconcile] error asserting metav1.Object as runtime.Object: %v", obj.GetName())
 				continue
 			}
 
-			controllerName := controllerRef.Name
-			objList := result[controllerName]
-			result[controllerName] = append(objList, &subresource{resourceClient, runtimeObj, subLifecycle})
+			result[controllerName] = append(result[controllerName], &subresource{
+				client:       registration.Client,
+				object:       runtimeObj,
+				lifecycle:    subLifecycle,
+				registration: registration.name(),
+				name:         obj.GetName(),
+			})
 		}
 	}
 
-	// Iterate over the crs to get the list of missing sub resources
-	// ASSUMPTION: There is at most one subresource of each kind per
-	//             custom resource. We use the plural form as a key
+	return result
+}
+
+// controllerName returns the name of the custom resource that controls the
+// supplied sub-resource. Retained sub-resources have no controller reference
+// and belong to the custom resource named by their standard labels instead.
+func (r *Reconciler) controllerName(obj metav1.Object) (string, bool) {
+	controllerRef := metav1.GetControllerOf(obj)
+	if controllerRef == nil {
+		labels := obj.GetLabels()
+		if obj.GetAnnotations()[resource.RetainAnnotation] == "true" &&
+			labels[resource.ManagedByLabel] == resource.ManagedBy &&
+			labels[resource.PartOfLabel] == r.partOf() &&
+			labels[resource.InstanceLabel] != "" {
+			return labels[resource.InstanceLabel], true
+		}
+		glog.V(4).Infof("[reconcile] ignoring sub-resource %v, %v as it doesn not have a controller reference", obj.GetName(), r.namespace)
+		return "", false
+	}
+	// Only manipulate controller-created subresources.
+	if controllerRef.APIVersion != r.gvk.GroupVersion().String() || controllerRef.Kind != r.gvk.Kind {
+		glog.V(4).Infof("[reconcile] ignoring sub-resource %v, %v as controlling custom resource is from a different group, version and kind", obj.GetName(), r.namespace)
+		return "", false
+	}
+	return controllerRef.Name, true
+}
+
+// registrationFor returns the registration that manages the object of the
+// kind with the supplied plural form and with the supplied name, controlled
+// by the custom resource with the supplied name. If several registrations
+// manage the kind, the object must bear one of their member names.
+func (r *Reconciler) registrationFor(plural string, crName string, objName string) (Registration, bool) {
+	var candidates []Registration
+	for _, registrationName := range r.registrationNames {
+		registration := r.registrations[registrationName]
+		if registration.Client.Plural() != plural {
+			continue
+		}
+		for _, name := range registration.memberNames(crName) {
+			if name == objName {
+				return registration, true
+			}
+		}
+		candidates = append(candidates, registration)
+	}
+	if len(candidates) == 1 {
+		return candidates[0], true
+	}
+	return Registration{}, false
+}
+
+// addMissingSubresources adds a non-existing subresource to the supplied map
+// for each member of each registration that a custom resource in the
+// supplied list lacks.
+func (r *Reconciler) addMissingSubresources(result subresourceMap, crList []runtime.Object) {
 	for _, item := range crList {
 		cr, ok := item.(crd.CustomResource)
 		if !ok {
@@ -213,22 +444,76 @@ func (r *Reconciler) groupSubresourcesByCustomResource() subresourceMap {
 			glog.Warningf("[reconcile] no sub-resources found for cr %v", cr.Name())
 		}
 
-		// Find non-existing subresources based on the expected subresource clients.
-		existingSubs := map[string]struct{}{}
-		for _, sub := range subs {
-			existingSubs[sub.client.Plural()] = struct{}{}
-		}
-
-		for _, subClient := range r.resourceClients {
-			_, exists := existingSubs[subClient.Plural()]
-			if !exists {
-				result[cr.Name()] = append(subs, &subresource{subClient, nil, doesNotExist})
+		// Find the non-existing members of each registration. Objects named
+		// by their template rather than by the naming strategy stand in for
+		// the members they do not name.
+		for _, registrationName := range r.registrationNames {
+			registration := r.registrations[registrationName]
+			existingNames := map[string]struct{}{}
+			for _, sub := range subs {
+				if sub.registrationName() == registrationName {
+					existingNames[sub.name] = struct{}{}
+				}
+			}
+			missing := registration.count() - len(existingNames)
+			for _, name := range registration.memberNames(cr.Name()) {
+				if missing <= 0 {
+					break
+				}
+				if _, exists := existingNames[name]; exists {
+					continue
+				}
+				if sub, ok := r.getSubresource(registration, cr.Name(), name); ok {
+					subs = append(subs, sub)
+					missing--
+					continue
+				}
+				subs = append(subs, &subresource{
+					client:       registration.Client,
+					lifecycle:    doesNotExist,
+					registration: registrationName,
+					name:         name,
+				})
+				missing--
 			}
 		}
+		result[cr.Name()] = subs
+	}
+}
 
+// getSubresource gets the subresource with the supplied name, controlled by
+// the custom resource with the supplied name, before it is declared missing.
+// The subresource may have been created since the last listing, or, for
+// cached clients, the informer may not have caught up with it yet. Treating
+// it as missing would fail custom resources whose subresources are not
+// ephemeral.
+func (r *Reconciler) getSubresource(registration Registration, crName string, name string) (*subresource, bool) {
+	obj, err := registration.Client.Get(r.namespace, name)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			glog.Warningf(`[reconcile] failed to get "%s" subresource "%s": %v`, registration.Client.Plural(), name, err)
+		}
+		return nil, false
+	}
+	objMeta, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, false
+	}
+	if controllerName, ok := r.controllerName(objMeta); !ok || controllerName != crName {
+		return nil, false
 	}
 
-	return result
+	subLifecycle := exists
+	if objMeta.GetDeletionTimestamp() != nil {
+		subLifecycle = deleting
+	}
+	return &subresource{
+		client:       registration.Client,
+		object:       obj,
+		lifecycle:    subLifecycle,
+		registration: registration.name(),
+		name:         name,
+	}, true
 }
 
 func (subs subresources) filter(predicate func(s *subresource) bool) subresources {
@@ -249,6 +534,152 @@ func (subs subresources) all(predicate func(s *subresource) bool) bool {
 	return len(subs.filter(predicate)) == len(subs)
 }
 
+// byRegistration groups subresources by the name of their registration.
+func (subs subresources) byRegistration() map[string]subresources {
+	result := map[string]subresources{}
+	for _, sub := range subs {
+		name := sub.registrationName()
+		result[name] = append(result[name], sub)
+	}
+	return result
+}
+
+// isOptional returns true if the subresource's client was registered as
+// optional.
+func (r *Reconciler) isOptional(s *subresource) bool {
+	return r.registrations[s.registrationName()].Optional
+}
+
+// subresourceStatuses returns the observed state of every existing
+// subresource.
+func (r *Reconciler) subresourceStatuses(subs subresources) []SubresourceStatus {
+	var result []SubresourceStatus
+	for _, s := range subs {
+		if s.lifecycle == doesNotExist || s.object == nil {
+			continue
+		}
+		objMeta, err := meta.Accessor(s.object)
+		if err != nil {
+			continue
+		}
+		status := SubresourceStatus{
+			Plural:           s.client.Plural(),
+			Name:             objMeta.GetName(),
+			State:            s.client.GetStatusState(s.object),
+			Optional:         r.isOptional(s),
+			TemplateRejected: templateRejected(s),
+		}
+		if reasonClient, ok := resource.Unwrap(s.client).(resource.StatusReasonClient); ok {
+			status.Reason = reasonClient.GetStatusReason(s.object)
+		}
+		if progressClient, ok := resource.Unwrap(s.client).(resource.ProgressClient); ok {
+			status.Progress = progressClient.GetProgress(s.object)
+		}
+		if historyClient, ok := resource.Unwrap(s.client).(resource.JobHistoryClient); ok {
+			history, err := historyClient.GetJobHistory(s.object)
+			if err != nil {
+				glog.Warningf(`failed to get job history for "%s" subresource "%s": %v`, status.Plural, status.Name, err)
+			} else {
+				status.JobHistory = &history
+			}
+		}
+		result = append(result, status)
+	}
+	return result
+}
+
+func isFailed(s *subresource) bool {
+	return !s.client.IsEphemeral() &&
+		s.lifecycle.isOneOf(doesNotExist, deleting) ||
+		s.client.GetStatusState(s.object) == states.Failed
+}
+
+// failureReason returns why the first failed subresource failed.
+func failureReason(subs subresources) string {
+	for _, s := range subs {
+		if !isFailed(s) {
+			continue
+		}
+		if s.lifecycle.isOneOf(doesNotExist, deleting) || s.object == nil {
+			return fmt.Sprintf(`"%s" subresource is missing`, s.client.Plural())
+		}
+		name := ""
+		if objMeta, err := meta.Accessor(s.object); err == nil {
+			name = objMeta.GetName()
+		}
+		if reasonClient, ok := resource.Unwrap(s.client).(resource.StatusReasonClient); ok {
+			if reason := reasonClient.GetStatusReason(s.object); reason != "" {
+				return fmt.Sprintf(`"%s" subresource "%s" failed: %s`, s.client.Plural(), name, reason)
+			}
+		}
+		return fmt.Sprintf(`"%s" subresource "%s" failed`, s.client.Plural(), name)
+	}
+	return ""
+}
+
+func isRunning(s *subresource) bool {
+	return s.client.GetStatusState(s.object) == states.Running
+}
+
+func isPending(s *subresource) bool {
+	return s.client.GetStatusState(s.object) == states.Pending
+}
+
+// quorumFor returns the quorum for the subresources of the supplied custom
+// resource managed by the registration with the supplied name. A quorum
+// declared by the custom resource itself takes precedence over the
+// registered one.
+func (r *Reconciler) quorumFor(cr crd.CustomResource, registrationName string) Quorum {
+	if qcr, ok := cr.(QuorumCustomResource); ok {
+		if quorum, ok := qcr.GetQuorum(registrationName); ok {
+			return quorum
+		}
+	}
+	return r.registrations[registrationName].Quorum
+}
+
+// quorumFailed returns true if, for any registration, more subresources
+// have failed than its quorum tolerates.
+func (r *Reconciler) quorumFailed(cr crd.CustomResource, subs subresources) bool {
+	for name, group := range subs.byRegistration() {
+		if len(group.filter(isFailed)) > r.quorumFor(cr, name).MaxFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// quorumRunning returns true if, for every registration, enough
+// subresources are running to satisfy its quorum.
+func (r *Reconciler) quorumRunning(cr crd.CustomResource, subs subresources) bool {
+	for name, group := range subs.byRegistration() {
+		quorum := r.quorumFor(cr, name)
+		running := len(group.filter(isRunning))
+		if quorum.MinRunning == 0 && running != len(group) {
+			return false
+		}
+		if running < quorum.MinRunning {
+			return false
+		}
+	}
+	return true
+}
+
+// quorumPending returns true if, for any registration, a subresource is
+// pending and the remaining running subresources do not satisfy its quorum.
+func (r *Reconciler) quorumPending(cr crd.CustomResource, subs subresources) bool {
+	for name, group := range subs.byRegistration() {
+		if !group.any(isPending) {
+			continue
+		}
+		quorum := r.quorumFor(cr, name)
+		if quorum.MinRunning == 0 || len(group.filter(isRunning)) < quorum.MinRunning {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Reconciler) planAction(controllerName string, subs subresources) (*action, crd.CustomResource, error) {
 	// If the controller name is empty, these are not our subresources;
 	// do nothing.
@@ -280,9 +711,27 @@ func (r *Reconciler) planAction(controllerName string, subs subresources) (*acti
 		return &action{}, nil, fmt.Errorf("object retrieved from CRD client not an instance of crd.CustomResource: [%v]", crObj)
 	}
 
+	// If another reconciler is responsible for the custom resource, do
+	// nothing.
+	if !r.claims(cr) {
+		return &action{}, nil, nil
+	}
+
 	customResourceSpecState := cr.GetSpecState()
 	customResourceStatusState := cr.GetStatusState()
 
+	// Optional subresources never affect the custom resource state.
+	required := subs.filter(func(s *subresource) bool {
+		return !r.isOptional(s)
+	})
+
+	// If a restart is in progress, wait for the previous subresources to be
+	// deleted and for the restart backoff to elapse, then recreate them.
+	rcr, restartable := cr.(RestartableCustomResource)
+	if restartable && rcr.GetRestartTime() != nil {
+		return planRestart(rcr, subs), cr, nil
+	}
+
 	// If the desired custom resource state is running or completed AND
 	// the custom resource is in a terminal state, then delete all subresources.
 	if customResourceSpecState.IsOneOf(states.Running, states.Completed) &&
@@ -291,19 +740,20 @@ func (r *Reconciler) planAction(controllerName string, subs subresources) (*acti
 	}
 
 	// If the desired custom resource state is running or completed AND
-	// the current custom resource status is non-terminal, ANY non-ephemeral
-	// subresource that is failed, does not exist or has been deleted causes
-	// the custom resource current state to move to failed.
+	// the current custom resource status is non-terminal, more failed
+	// subresources than the quorum tolerates causes the custom resource
+	// current state to move to failed. A non-ephemeral subresource that does
+	// not exist or has been deleted counts as failed.
 	if customResourceSpecState.IsOneOf(states.Running, states.Completed) &&
 		customResourceStatusState.IsOneOf(states.Pending, states.Running) {
-		if subs.any(func(s *subresource) bool {
-			return !s.client.IsEphemeral() &&
-				s.lifecycle.isOneOf(doesNotExist, deleting) ||
-				s.client.GetStatusState(s.object) == states.Failed
-		}) {
+		if r.quorumFailed(cr, required) {
+			if restartable && shouldRestart(rcr, states.Failed) {
+				return beginRestart(rcr, subs, states.Failed), cr, nil
+			}
 			// Set CR to failed
 			return &action{
-				newCRState: states.Failed,
+				newCRState:  states.Failed,
+				newCRReason: failureReason(required),
 			}, cr, nil
 		}
 	}
@@ -313,9 +763,12 @@ func (r *Reconciler) planAction(controllerName string, subs subresources) (*acti
 	// subresource is completed, set the current custom resource state to
 	// completed.
 	if customResourceSpecState == states.Completed && customResourceStatusState.IsOneOf(states.Pending, states.Running) {
-		if subs.any(func(s *subresource) bool {
+		if required.any(func(s *subresource) bool {
 			return s.client.GetStatusState(s.object) == states.Completed
 		}) {
+			if restartable && shouldRestart(rcr, states.Completed) {
+				return beginRestart(rcr, subs, states.Completed), cr, nil
+			}
 			// Set CR as completed
 			return &action{
 				newCRState: states.Completed,
@@ -325,30 +778,31 @@ func (r *Reconciler) planAction(controllerName string, subs subresources) (*acti
 
 	// If the desired custom resource state is running or completed AND
 	// the current custom resource state is pending or running, then
-	// re-create any nonexisting ephemeral subresources.
+	// re-create any nonexisting ephemeral subresources, and delete any failed
+	// ones so that they are recreated once they are gone.
 	if customResourceSpecState.IsOneOf(states.Running, states.Completed) &&
 		customResourceStatusState.IsOneOf(states.Pending, states.Running) {
-		toRecreate := subs.filter(func(s *subresource) bool {
-			return s.client.IsEphemeral() &&
-				(s.lifecycle == exists && s.client.GetStatusState(s.object) == states.Failed ||
-					s.lifecycle == doesNotExist)
+		toCreate := subs.filter(func(s *subresource) bool {
+			return s.client.IsEphemeral() && s.lifecycle == doesNotExist
+		})
+		toDelete := subs.filter(func(s *subresource) bool {
+			return s.client.IsEphemeral() && s.lifecycle == exists &&
+				s.client.GetStatusState(s.object) == states.Failed
 		})
 
-		if len(toRecreate) > 0 {
+		if len(toCreate)+len(toDelete) > 0 {
 			// Recreate
-			return &action{subresourcesToCreate: toRecreate}, cr, nil
+			return &action{subresourcesToCreate: toCreate, subresourcesToDelete: toDelete}, cr, nil
 		}
 	}
 
 	// If the desired custom resource state is running or completed AND
 	// the current custom resource state is running AND
-	// ANY subresource is pending, then set the current custom resource state
-	// to pending.
+	// ANY subresource is pending and the quorum is no longer met, then set
+	// the current custom resource state to pending.
 	if customResourceSpecState.IsOneOf(states.Running, states.Completed) &&
 		customResourceStatusState == states.Running {
-		if subs.any(func(s *subresource) bool {
-			return s.client.GetStatusState(s.object) == states.Pending
-		}) {
+		if r.quorumPending(cr, required) {
 			// Set CR as pending
 			return &action{
 				newCRState: states.Pending,
@@ -358,14 +812,13 @@ func (r *Reconciler) planAction(controllerName string, subs subresources) (*acti
 
 	// If the desired custom resource state is running or completed AND
 	// the current custom resource state is pending AND
-	// ALL subresources are running, then set the current custom resource state
-	// to running.
+	// the quorum of running subresources is met, then set the current custom
+	// resource state to running.
 	if customResourceSpecState.IsOneOf(states.Running, states.Completed) &&
 		customResourceStatusState == states.Pending {
-		// All resources must be running for us to consider the custom resource as running.
-		if subs.all(func(s *subresource) bool {
-			return s.client.GetStatusState(s.object) == states.Running
-		}) {
+		// By default all resources must be running for us to consider the
+		// custom resource as running.
+		if r.quorumRunning(cr, required) {
 			// Set CR as running
 			return &action{
 				newCRState: states.Running,
@@ -373,17 +826,149 @@ func (r *Reconciler) planAction(controllerName string, subs subresources) (*acti
 		}
 	}
 
+	// If the desired custom resource state is running or completed AND
+	// the current custom resource state is pending or running, then update
+	// the subresources whose template changed, e.g. to roll the pods of a
+	// deployment when the config map checksum in its template changes.
+	if customResourceSpecState.IsOneOf(states.Running, states.Completed) &&
+		customResourceStatusState.IsOneOf(states.Pending, states.Running) {
+		owner := r.owner(cr)
+		toUpdate := subs.filter(func(s *subresource) bool {
+			return templateChanged(s, owner)
+		})
+
+		if len(toUpdate) > 0 {
+			return &action{subresourcesToUpdate: toUpdate}, cr, nil
+		}
+	}
+
 	// Default case: do nothing.
 	return &action{}, cr, nil
 }
 
+// owner returns the supplied custom resource as the template values of its
+// subresources. Custom resources decoded by the CRD client have no type
+// metadata, so it is set on a copy so that clients can inject the controller
+// reference, together with the part-of label of the subresources.
+func (r *Reconciler) owner(cr crd.CustomResource) runtime.Object {
+	owner := cr.DeepCopyObject()
+	owner.GetObjectKind().SetGroupVersionKind(r.gvk)
+	if ownerMeta, err := meta.Accessor(owner); err == nil {
+		labels := map[string]string{}
+		for key, value := range ownerMeta.GetLabels() {
+			labels[key] = value
+		}
+		labels[resource.PartOfLabel] = r.partOf()
+		ownerMeta.SetLabels(labels)
+	}
+	return owner
+}
+
+// partOf returns the value of the part-of label of the subresources: the
+// plural form of the custom resource kind.
+func (r *Reconciler) partOf() string {
+	if r.crdHandle != nil && r.crdHandle.Plural != "" {
+		return r.crdHandle.Plural
+	}
+	return strings.ToLower(r.gvk.Kind)
+}
+
+// templateChanged returns true if the supplied existing and non-terminal
+// subresource was created or last updated from a template that reified
+// differently for the supplied owner. Subresources without a template
+// checksum are left alone, and so are those whose update to the current
+// template was rejected.
+func templateChanged(s *subresource, owner runtime.Object) bool {
+	if s.lifecycle != exists || s.client.GetStatusState(s.object).IsOneOf(states.Completed, states.Failed) {
+		return false
+	}
+	objMeta, err := meta.Accessor(s.object)
+	if err != nil {
+		return false
+	}
+	checksum, ok := objMeta.GetAnnotations()[resource.TemplateChecksumAnnotation]
+	if !ok {
+		return false
+	}
+	current, err := templateChecksum(s.client, owner)
+	if err != nil {
+		glog.Warningf(`[reconcile] failed to reify "%s" subresource "%s": %v`, s.client.Plural(), s.name, err)
+		return false
+	}
+	rejected, isRejected := objMeta.GetAnnotations()[resource.RejectedTemplateChecksumAnnotation]
+	if current == checksum {
+		// The template changed back since an update was rejected. Update
+		// the subresource once more to clear the rejection.
+		return isRejected
+	}
+	return current != rejected
+}
+
+// templateChecksum returns the checksum of the template of the supplied
+// client reified for the supplied owner. Clients that implement
+// resource.TemplateChecksumClient render the template without logging it.
+func templateChecksum(client resource.Client, owner runtime.Object) (string, error) {
+	if checksumClient, ok := resource.Unwrap(client).(resource.TemplateChecksumClient); ok {
+		return checksumClient.TemplateChecksum(owner)
+	}
+	body, err := client.Reify(owner)
+	if err != nil {
+		return "", err
+	}
+	return resource.TemplateChecksum(body), nil
+}
+
+// templateRejected returns true if the API server rejected the update of the
+// supplied subresource to its current template.
+func templateRejected(s *subresource) bool {
+	objMeta, err := meta.Accessor(s.object)
+	if err != nil {
+		return false
+	}
+	_, ok := objMeta.GetAnnotations()[resource.RejectedTemplateChecksumAnnotation]
+	return ok
+}
+
+// setRejectedTemplate records the supplied checksum as that of a template
+// the supplied subresource cannot be updated to, or clears the record if the
+// checksum is empty.
+func (r *Reconciler) setRejectedTemplate(s *subresource, checksum string) error {
+	var value interface{}
+	if checksum != "" {
+		value = checksum
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]interface{}{resource.RejectedTemplateChecksumAnnotation: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PatchObject(r.namespace, s.name, types.MergePatchType, patch)
+	return err
+}
+
 func (r *Reconciler) executeAction(controllerName string, cr crd.CustomResource, a *action) []error {
 	errors := []error{}
 
 	glog.V(4).Infof(`executing reconcile action for "%s" resource "%s" in namespace "%s"`, r.crdHandle.Plural, controllerName, r.namespace)
+	updateCR := false
 	if a.newCRState != "" {
-		glog.Infof(`updating "%s" custom resource for controller "%s" in namespace "%s"`, r.crdHandle.Plural, controllerName, r.namespace)
 		cr.SetStatusStateWithMessage(a.newCRState, a.newCRReason)
+		updateCR = true
+	}
+	if rcr, ok := cr.(RestartableCustomResource); ok && a.restart != nil {
+		rcr.SetRestartStatus(a.restart.count, a.restart.time)
+		updateCR = true
+	}
+	if reporter, ok := cr.(SubresourceStatusCustomResource); ok && len(a.subresourceStatuses) > 0 {
+		if reporter.SetSubresourceStatuses(a.subresourceStatuses) {
+			updateCR = true
+		}
+	}
+	if updateCR {
+		glog.Infof(`updating "%s" custom resource for controller "%s" in namespace "%s"`, r.crdHandle.Plural, controllerName, r.namespace)
 		_, err := r.crdClient.Update(cr)
 		if err != nil {
 			glog.Errorf(`error updating custom resource state for "%s" in namespace "%s"`, controllerName, r.namespace)
@@ -391,23 +976,103 @@ func (r *Reconciler) executeAction(controllerName string, cr crd.CustomResource,
 		}
 	}
 
+	var owner runtime.Object
+	if cr != nil && len(a.subresourcesToCreate)+len(a.subresourcesToUpdate) > 0 {
+		owner = r.owner(cr)
+	}
 	for _, s := range a.subresourcesToCreate {
-		glog.Infof(`creating "%s" subresource for controller "%s" in namespace "%s"`, s.client.Plural(), controllerName, r.namespace)
-		err := s.client.Create(r.namespace, cr)
+		glog.Infof(`creating "%s" subresource "%s" for controller "%s" in namespace "%s"`, s.client.Plural(), s.name, controllerName, r.namespace)
+		err := r.createSubresource(s, controllerName, owner)
+		if err != nil {
+			glog.Errorf(`error creating "%s" subresource "%s" for controller "%s" in namespace "%s"`, s.client.Plural(), s.name, controllerName, r.namespace)
+			errors = append(errors, err)
+		}
+	}
+
+	for _, s := range a.subresourcesToUpdate {
+		glog.Infof(`updating "%s" subresource "%s" for controller "%s" in namespace "%s"`, s.client.Plural(), s.name, controllerName, r.namespace)
+		_, err := s.client.Apply(r.namespace, s.name, owner, r.options.Apply)
+		if isUnsupportedMediaType(err) {
+			// The API server does not support server-side apply.
+			_, err = s.client.UpdateObject(r.namespace, s.name, owner)
+		}
+		switch {
+		case apierrors.IsInvalid(err) && s.client.IsEphemeral():
+			// Some fields, such as the spec of a pod, cannot be updated.
+			// Delete the ephemeral subresource so that it is recreated.
+			glog.Infof(`recreating "%s" subresource "%s" for controller "%s" in namespace "%s": %v`, s.client.Plural(), s.name, controllerName, r.namespace, err)
+			err = s.client.Delete(r.namespace, s.name)
+		case apierrors.IsInvalid(err):
+			// Non-ephemeral subresources, such as jobs, are left as they
+			// are. Record the rejected template, so that the update is not
+			// retried until the template changes again.
+			glog.Errorf(`"%s" subresource "%s" for controller "%s" in namespace "%s" cannot be updated to its template: %v`, s.client.Plural(), s.name, controllerName, r.namespace, err)
+			checksum, checksumErr := templateChecksum(s.client, owner)
+			if checksumErr == nil {
+				checksumErr = r.setRejectedTemplate(s, checksum)
+			}
+			if checksumErr != nil {
+				errors = append(errors, checksumErr)
+			}
+		case err == nil && templateRejected(s):
+			err = r.setRejectedTemplate(s, "")
+		}
 		if err != nil {
-			glog.Errorf(`error creating "%s" subresource for controller "%s" in namespace "%s"`, s.client.Plural(), controllerName, r.namespace)
+			glog.Errorf(`error updating "%s" subresource "%s" for controller "%s" in namespace "%s"`, s.client.Plural(), s.name, controllerName, r.namespace)
 			errors = append(errors, err)
 		}
 	}
 
 	for _, s := range a.subresourcesToDelete {
-		glog.Infof(`deleting "%s" subresource for controller "%s" in namespace "%s"`, s.client.Plural(), controllerName, r.namespace)
-		err := s.client.Delete(r.namespace, controllerName)
+		if s.lifecycle == doesNotExist {
+			continue
+		}
+		glog.Infof(`deleting "%s" subresource "%s" for controller "%s" in namespace "%s"`, s.client.Plural(), s.name, controllerName, r.namespace)
+		err := s.client.Delete(r.namespace, s.name)
 		if err != nil {
-			glog.Errorf(`error deleting "%s" subresource for controller "%s" in namespace "%s"`, s.client.Plural(), controllerName, r.namespace)
+			glog.Errorf(`error deleting "%s" subresource "%s" for controller "%s" in namespace "%s"`, s.client.Plural(), s.name, controllerName, r.namespace)
 			errors = append(errors, err)
 		}
 	}
 
 	return errors
 }
+
+// isUnsupportedMediaType returns true if the supplied error is an API error
+// for a request body of an unsupported type.
+func isUnsupportedMediaType(err error) bool {
+	status, ok := err.(apierrors.APIStatus)
+	return ok && status.Status().Code == http.StatusUnsupportedMediaType
+}
+
+// createSubresource creates the supplied non-existing subresource for the
+// custom resource with the supplied name. Subresources named after the custom
+// resource are named by their template.
+func (r *Reconciler) createSubresource(s *subresource, crName string, owner runtime.Object) error {
+	if s.name == "" || s.name == crName {
+		return s.client.Create(r.namespace, owner)
+	}
+	namedClient, ok := resource.Unwrap(s.client).(resource.NamedClient)
+	if !ok {
+		return fmt.Errorf(`"%s" client cannot create subresources named "%s"`, s.client.Plural(), s.name)
+	}
+	_, err := namedClient.CreateNamed(r.namespace, s.name, owner)
+	return err
+}
+
+// GetSubresource returns the subresource managed by the registration with the
+// supplied name, which defaults to the plural form of its client, for the
+// custom resource with the supplied name. For registrations that maintain
+// several subresources, it returns the first one. It may be called from
+// controller hooks.
+func (r *Reconciler) GetSubresource(registrationName string, crName string) (runtime.Object, error) {
+	registration, ok := r.registrations[registrationName]
+	if !ok {
+		return nil, fmt.Errorf(`no client registered as "%s"`, registrationName)
+	}
+	names := registration.memberNames(crName)
+	if len(names) == 0 {
+		return nil, fmt.Errorf(`registration "%s" can't name the subresources of "%s"`, registrationName, crName)
+	}
+	return registration.Client.Get(r.namespace, names[0])
+}
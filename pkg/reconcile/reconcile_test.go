@@ -19,13 +19,24 @@
 package reconcile
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apilabels "k8s.io/apimachinery/pkg/labels"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 
 	"fmt"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/crd"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/crd/fake"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource"
 	rf "github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/fake"
@@ -262,14 +273,9 @@ func TestGroupSubresourcesByCustomResource(t *testing.T) {
 	// Assumption:  Failure to convert an object into either a runtime.Object or a Subresource is considered as a
 	//				doesNotExist sub-resource.
 	for _, tc := range tests {
-		reconciler := &Reconciler{
-			namespace:       tc.namespace,
-			gvk:             tc.gvk,
-			crdHandle:       nil,
-			crdClient:       &fake.ClientImpl{CustomResourceListImpl: &tc.crList},
-			resourceClients: tc.resourceClients,
-		}
+		reconciler := New(tc.namespace, tc.gvk, nil, &fake.ClientImpl{CustomResourceListImpl: &tc.crList}, tc.resourceClients)
 		actual := reconciler.groupSubresourcesByCustomResource()
+		reconciler.queue.ShutDown()
 
 		for controllerName := range actual {
 
@@ -314,3 +320,1303 @@ func TestGroupSubresourcesByCustomResource(t *testing.T) {
 	}
 
 }
+
+// subresourcesWithStatus returns one non-ephemeral subresource managed by the
+// supplied client for each of the supplied status states.
+func subresourcesWithStatus(client resource.Client, statuses ...states.State) subresources {
+	var result subresources
+	for _, status := range statuses {
+		result = append(result, &subresource{
+			client:    client,
+			object:    &rf.Subresource{StatusState: status},
+			lifecycle: exists,
+		})
+	}
+	return result
+}
+
+func TestPlanActionQuorum(t *testing.T) {
+	podClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{},
+		PluralValue: "pods",
+	}
+	ephemeralPodClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{Ephemeral: true},
+		PluralValue: "pods",
+	}
+	missing := func(client resource.Client) *subresource {
+		return &subresource{client: client, lifecycle: doesNotExist}
+	}
+	tests := map[string]struct {
+		quorum        Quorum
+		crStatusState states.State
+		subs          subresources
+		expected      states.State
+		created       int
+		deleted       int
+	}{
+		"all running by default": {
+			quorum:        Quorum{},
+			crStatusState: states.Pending,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Running),
+			expected:      states.Running,
+		},
+		"one pending by default": {
+			quorum:        Quorum{},
+			crStatusState: states.Pending,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Pending),
+			expected:      "",
+		},
+		"minimum running met": {
+			quorum:        Quorum{MinRunning: 2},
+			crStatusState: states.Pending,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Running, states.Pending),
+			expected:      states.Running,
+		},
+		"minimum running not met": {
+			quorum:        Quorum{MinRunning: 2},
+			crStatusState: states.Pending,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Pending, states.Pending),
+			expected:      "",
+		},
+		"stays running while minimum running met": {
+			quorum:        Quorum{MinRunning: 2},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Running, states.Pending),
+			expected:      "",
+		},
+		"pending when minimum running lost": {
+			quorum:        Quorum{MinRunning: 2},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Pending, states.Pending),
+			expected:      states.Pending,
+		},
+		"any failure by default": {
+			quorum:        Quorum{},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Failed),
+			expected:      states.Failed,
+		},
+		"failures tolerated": {
+			quorum:        Quorum{MaxFailed: 1},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Running, states.Failed),
+			expected:      "",
+		},
+		"failures exceed tolerance": {
+			quorum:        Quorum{MaxFailed: 1},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(podClient, states.Running, states.Failed, states.Failed),
+			expected:      states.Failed,
+		},
+		"tolerated ephemeral failure deleted": {
+			quorum:        Quorum{MaxFailed: 1},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(ephemeralPodClient, states.Running, states.Running, states.Failed),
+			expected:      "",
+			deleted:       1,
+		},
+		"missing ephemeral subresource created": {
+			quorum:        Quorum{MaxFailed: 1},
+			crStatusState: states.Running,
+			subs:          append(subresourcesWithStatus(ephemeralPodClient, states.Running, states.Running), missing(ephemeralPodClient)),
+			expected:      "",
+			created:       1,
+		},
+		"ephemeral failures exceed tolerance": {
+			quorum:        Quorum{MaxFailed: 1},
+			crStatusState: states.Running,
+			subs:          subresourcesWithStatus(ephemeralPodClient, states.Running, states.Failed, states.Failed),
+			expected:      states.Failed,
+		},
+	}
+
+	for name, tc := range tests {
+		reconciler := &Reconciler{
+			namespace: "namespace1",
+			crdClient: &fake.ClientImpl{
+				CustomResourceImpl: &fake.CustomResourceImpl{
+					ObjectMeta:  metav1.ObjectMeta{Name: "crdkind11"},
+					SpecState:   states.Running,
+					StatusState: tc.crStatusState,
+				},
+			},
+			registrations: map[string]Registration{
+				"pods": {Client: podClient, Quorum: tc.quorum},
+			},
+		}
+		a, _, err := reconciler.planAction("crdkind11", tc.subs)
+		assert.Nil(t, err, name)
+		assert.Equal(t, tc.expected, a.newCRState, name)
+		// Existing subresources are never created again.
+		assert.Len(t, a.subresourcesToCreate, tc.created, name)
+		assert.Len(t, a.subresourcesToDelete, tc.deleted, name)
+	}
+}
+
+// quorumCustomResource is a custom resource that declares its own quorum.
+type quorumCustomResource struct {
+	*fake.CustomResourceImpl
+	quorums map[string]Quorum
+}
+
+func (q *quorumCustomResource) GetQuorum(plural string) (Quorum, bool) {
+	quorum, ok := q.quorums[plural]
+	return quorum, ok
+}
+
+func TestQuorumFor(t *testing.T) {
+	reconciler := &Reconciler{
+		registrations: map[string]Registration{
+			"pods": {Quorum: Quorum{MinRunning: 1}},
+			"jobs": {Quorum: Quorum{MaxFailed: 1}},
+		},
+	}
+	cr := &quorumCustomResource{
+		CustomResourceImpl: &fake.CustomResourceImpl{},
+		quorums: map[string]Quorum{
+			"pods": {MinRunning: 3, MaxFailed: 2},
+		},
+	}
+
+	assert.Equal(t, Quorum{MinRunning: 3, MaxFailed: 2}, reconciler.quorumFor(cr, "pods"))
+	assert.Equal(t, Quorum{MaxFailed: 1}, reconciler.quorumFor(cr, "jobs"))
+	assert.Equal(t, Quorum{}, reconciler.quorumFor(cr, "services"))
+	assert.Equal(t, Quorum{MinRunning: 1}, reconciler.quorumFor(&fake.CustomResourceImpl{}, "pods"))
+}
+
+func TestPlanActionOptional(t *testing.T) {
+	podClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{},
+		PluralValue: "pods",
+	}
+	serviceClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{},
+		PluralValue: "services",
+	}
+	hpaClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{Ephemeral: true},
+		PluralValue: "horizontalpodautoscalers",
+	}
+	tests := map[string]struct {
+		crStatusState states.State
+		subs          subresources
+		expected      states.State
+		deleted       int
+	}{
+		"optional failure ignored": {
+			crStatusState: states.Running,
+			subs: append(
+				subresourcesWithStatus(podClient, states.Running),
+				subresourcesWithStatus(serviceClient, states.Failed)...),
+			expected: "",
+		},
+		"optional pending ignored": {
+			crStatusState: states.Pending,
+			subs: append(
+				subresourcesWithStatus(podClient, states.Running),
+				subresourcesWithStatus(serviceClient, states.Pending)...),
+			expected: states.Running,
+		},
+		"required failure fails": {
+			crStatusState: states.Running,
+			subs: append(
+				subresourcesWithStatus(podClient, states.Failed),
+				subresourcesWithStatus(serviceClient, states.Running)...),
+			expected: states.Failed,
+		},
+		"optional ephemeral failure deleted": {
+			crStatusState: states.Running,
+			subs: append(
+				subresourcesWithStatus(podClient, states.Running),
+				subresourcesWithStatus(hpaClient, states.Failed)...),
+			expected: "",
+			deleted:  1,
+		},
+	}
+
+	for name, tc := range tests {
+		reconciler := &Reconciler{
+			namespace: "namespace1",
+			crdClient: &fake.ClientImpl{
+				CustomResourceImpl: &fake.CustomResourceImpl{
+					ObjectMeta:  metav1.ObjectMeta{Name: "crdkind11"},
+					SpecState:   states.Running,
+					StatusState: tc.crStatusState,
+				},
+			},
+			registrations: map[string]Registration{
+				"pods":                     {Client: podClient},
+				"services":                 {Client: serviceClient, Optional: true},
+				"horizontalpodautoscalers": {Client: hpaClient, Optional: true},
+			},
+		}
+		a, _, err := reconciler.planAction("crdkind11", tc.subs)
+		assert.Nil(t, err, name)
+		assert.Equal(t, tc.expected, a.newCRState, name)
+		// Existing subresources are never created again.
+		assert.Empty(t, a.subresourcesToCreate, name)
+		assert.Len(t, a.subresourcesToDelete, tc.deleted, name)
+	}
+}
+
+// jobHistoryClient is a subresource client whose subresources spawn jobs.
+type jobHistoryClient struct {
+	*rf.SubresourceClient
+	history resource.JobHistory
+}
+
+func (c *jobHistoryClient) GetJobHistory(obj runtime.Object) (resource.JobHistory, error) {
+	return c.history, nil
+}
+
+func TestSubresourceStatusesJobHistory(t *testing.T) {
+	now := metav1.Now()
+	history := resource.JobHistory{
+		LastScheduleTime: &now,
+		Jobs: []resource.JobRecord{
+			{Name: "job2", State: states.Failed, StartTime: &now},
+			{Name: "job1", State: states.Completed},
+		},
+	}
+	cronJobClient := &jobHistoryClient{
+		SubresourceClient: &rf.SubresourceClient{
+			Subresource: &rf.Subresource{},
+			PluralValue: "cronjobs",
+		},
+		history: history,
+	}
+	serviceClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{},
+		PluralValue: "services",
+	}
+	reconciler := &Reconciler{
+		registrations: map[string]Registration{
+			"cronjobs": {Client: cronJobClient},
+			"services": {Client: serviceClient},
+		},
+	}
+
+	statuses := reconciler.subresourceStatuses(append(
+		subresourcesWithStatus(cronJobClient, states.Failed),
+		subresourcesWithStatus(serviceClient, states.Running)...))
+
+	assert.Len(t, statuses, 2)
+	assert.Equal(t, "cronjobs", statuses[0].Plural)
+	assert.Equal(t, states.Failed, statuses[0].State)
+	assert.Equal(t, &history, statuses[0].JobHistory)
+	assert.Nil(t, statuses[1].JobHistory)
+}
+
+// reasonClient is a subresource client that explains the state of its
+// subresources.
+type reasonClient struct {
+	*rf.SubresourceClient
+	reason string
+}
+
+func (c *reasonClient) GetStatusReason(obj runtime.Object) string {
+	return c.reason
+}
+
+func (c *reasonClient) GetProgress(obj runtime.Object) string {
+	return "3/8 completions"
+}
+
+func TestFailureReason(t *testing.T) {
+	podClient := &reasonClient{
+		SubresourceClient: &rf.SubresourceClient{
+			Subresource: &rf.Subresource{},
+			PluralValue: "pods",
+		},
+		reason: "container main: CrashLoopBackOff",
+	}
+	serviceClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{},
+		PluralValue: "services",
+	}
+	failedPod := &subresource{
+		client:    podClient,
+		object:    &rf.Subresource{ObjectMeta: metav1.ObjectMeta{Name: "pod1"}, StatusState: states.Failed},
+		lifecycle: exists,
+	}
+	failedService := &subresource{
+		client:    serviceClient,
+		object:    &rf.Subresource{ObjectMeta: metav1.ObjectMeta{Name: "service1"}, StatusState: states.Failed},
+		lifecycle: exists,
+	}
+	missingService := &subresource{
+		client:    serviceClient,
+		lifecycle: doesNotExist,
+	}
+
+	assert.Equal(t, "", failureReason(subresourcesWithStatus(podClient, states.Running)))
+	assert.Equal(t, `"pods" subresource "pod1" failed: container main: CrashLoopBackOff`, failureReason(subresources{failedPod}))
+	assert.Equal(t, `"services" subresource "service1" failed`, failureReason(subresources{failedService}))
+	assert.Equal(t, `"services" subresource is missing`, failureReason(subresources{missingService}))
+}
+
+func TestSubresourceStatusesReasonAndProgress(t *testing.T) {
+	jobClient := &reasonClient{
+		SubresourceClient: &rf.SubresourceClient{
+			Subresource: &rf.Subresource{},
+			PluralValue: "jobs",
+		},
+		reason: "waiting for a pod to run",
+	}
+	reconciler := &Reconciler{
+		registrations: map[string]Registration{
+			"jobs": {Client: jobClient},
+		},
+	}
+
+	statuses := reconciler.subresourceStatuses(subresourcesWithStatus(jobClient, states.Pending))
+
+	assert.Len(t, statuses, 1)
+	assert.Equal(t, "waiting for a pod to run", statuses[0].Reason)
+	assert.Equal(t, "3/8 completions", statuses[0].Progress)
+}
+
+// restartableCustomResource is a custom resource with a restart policy.
+type restartableCustomResource struct {
+	*fake.CustomResourceImpl
+	policy      RestartPolicy
+	maxRestarts int
+	count       int
+	restartTime *metav1.Time
+}
+
+func (r *restartableCustomResource) GetRestartPolicy() (RestartPolicy, int) {
+	return r.policy, r.maxRestarts
+}
+
+func (r *restartableCustomResource) GetRestartCount() int {
+	return r.count
+}
+
+func (r *restartableCustomResource) GetRestartTime() *metav1.Time {
+	return r.restartTime
+}
+
+func (r *restartableCustomResource) SetRestartStatus(count int, restartTime *metav1.Time) {
+	r.count = count
+	r.restartTime = restartTime
+}
+
+// customResourceClient is a fake crd.Client that returns a fixed custom
+// resource.
+type customResourceClient struct {
+	fake.ClientImpl
+	cr runtime.Object
+}
+
+func (c *customResourceClient) Get(namespace string, name string) (runtime.Object, error) {
+	return c.cr, nil
+}
+
+func TestPlanActionRestart(t *testing.T) {
+	podClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{},
+		PluralValue: "pods",
+	}
+	missing := subresources{{client: podClient, lifecycle: doesNotExist}}
+	longAgo := metav1.NewTime(time.Now().Add(-time.Hour))
+	justNow := metav1.Now()
+
+	tests := map[string]struct {
+		cr               *restartableCustomResource
+		subs             subresources
+		expectedState    states.State
+		expectedCreate   int
+		expectedDelete   int
+		expectedRestarts int
+	}{
+		"restart on failure": {
+			cr: &restartableCustomResource{
+				policy:      RestartPolicyOnFailure,
+				maxRestarts: 2,
+			},
+			subs:             subresourcesWithStatus(podClient, states.Running, states.Failed),
+			expectedState:    states.Pending,
+			expectedDelete:   2,
+			expectedRestarts: 1,
+		},
+		"restarts exhausted": {
+			cr: &restartableCustomResource{
+				policy:      RestartPolicyOnFailure,
+				maxRestarts: 2,
+				count:       2,
+			},
+			subs:             subresourcesWithStatus(podClient, states.Failed),
+			expectedState:    states.Failed,
+			expectedRestarts: 2,
+		},
+		"never restart": {
+			cr: &restartableCustomResource{
+				policy:      RestartPolicyNever,
+				maxRestarts: 2,
+			},
+			subs:          subresourcesWithStatus(podClient, states.Failed),
+			expectedState: states.Failed,
+		},
+		"waiting for deletion": {
+			cr: &restartableCustomResource{
+				policy:      RestartPolicyOnFailure,
+				maxRestarts: 2,
+				count:       1,
+				restartTime: &longAgo,
+			},
+			subs:             subresourcesWithStatus(podClient, states.Failed),
+			expectedDelete:   1,
+			expectedRestarts: 1,
+		},
+		"waiting for backoff": {
+			cr: &restartableCustomResource{
+				policy:      RestartPolicyOnFailure,
+				maxRestarts: 2,
+				count:       1,
+				restartTime: &justNow,
+			},
+			subs:             missing,
+			expectedRestarts: 1,
+		},
+		"recreate after backoff": {
+			cr: &restartableCustomResource{
+				policy:      RestartPolicyOnFailure,
+				maxRestarts: 2,
+				count:       1,
+				restartTime: &longAgo,
+			},
+			subs:             missing,
+			expectedCreate:   1,
+			expectedRestarts: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		tc.cr.CustomResourceImpl = &fake.CustomResourceImpl{
+			ObjectMeta:  metav1.ObjectMeta{Name: "crdkind11"},
+			SpecState:   states.Running,
+			StatusState: states.Running,
+		}
+		if tc.cr.restartTime != nil {
+			tc.cr.StatusState = states.Pending
+		}
+		reconciler := &Reconciler{
+			namespace: "namespace1",
+			crdClient: &customResourceClient{cr: tc.cr},
+		}
+		a, cr, err := reconciler.planAction("crdkind11", tc.subs)
+		assert.Nil(t, err, name)
+		assert.Equal(t, tc.expectedState, a.newCRState, name)
+		assert.Len(t, a.subresourcesToCreate, tc.expectedCreate, name)
+		assert.Len(t, a.subresourcesToDelete, tc.expectedDelete, name)
+		if a.restart != nil {
+			cr.(RestartableCustomResource).SetRestartStatus(a.restart.count, a.restart.time)
+		}
+		assert.Equal(t, tc.expectedRestarts, tc.cr.count, name)
+	}
+}
+
+func TestRestartBackoff(t *testing.T) {
+	assert.Equal(t, restartBackoffBase, restartBackoff(1))
+	assert.Equal(t, 2*restartBackoffBase, restartBackoff(2))
+	assert.Equal(t, 4*restartBackoffBase, restartBackoff(3))
+	assert.Equal(t, restartBackoffMax, restartBackoff(100))
+}
+
+// resyncCustomResource is a custom resource that declares its resync period.
+type resyncCustomResource struct {
+	*fake.CustomResourceImpl
+	period time.Duration
+}
+
+func (r *resyncCustomResource) GetResyncPeriod() time.Duration {
+	return r.period
+}
+
+func TestRequeueAfter(t *testing.T) {
+	reconciler := &Reconciler{interval: 10 * time.Second}
+	cr := &resyncCustomResource{
+		CustomResourceImpl: &fake.CustomResourceImpl{},
+		period:             time.Minute,
+	}
+
+	assert.Equal(t, time.Second, reconciler.requeueAfter(cr, &action{requeueAfter: time.Second}))
+	assert.Equal(t, time.Minute, reconciler.requeueAfter(cr, &action{}))
+	assert.Equal(t, 10*time.Second, reconciler.requeueAfter(&resyncCustomResource{CustomResourceImpl: &fake.CustomResourceImpl{}}, &action{}))
+	assert.Equal(t, 10*time.Second, reconciler.requeueAfter(&fake.CustomResourceImpl{}, &action{}))
+	assert.Equal(t, 10*time.Second, reconciler.requeueAfter(nil, &action{}))
+}
+
+func TestDiscover(t *testing.T) {
+	controllerRef := true
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	crList := fake.CustomResourceListImpl{
+		Items: []fake.CustomResourceImpl{
+			{
+				ObjectMeta:  metav1.ObjectMeta{Name: "crdkind11"},
+				SpecState:   states.Running,
+				StatusState: states.Running,
+			},
+		},
+	}
+	subresourceClient := &rf.SubresourceClient{
+		Subresource: &rf.Subresource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "pod1",
+				Namespace: "namespace1",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: "kubernetes.intel.com/v1",
+						Kind:       "CRDKind1",
+						Name:       "crdkind11",
+						UID:        "3982",
+						Controller: &controllerRef,
+					}},
+			},
+			StatusState: states.Running,
+		},
+		PluralValue: "pods",
+	}
+	reconciler := New("namespace1", gvk, nil, &fake.ClientImpl{CustomResourceListImpl: &crList}, []resource.Client{subresourceClient})
+	defer reconciler.queue.ShutDown()
+
+	reconciler.discover()
+	assert.Equal(t, 1, reconciler.queue.Len())
+
+	// Scheduled custom resources are not queued again.
+	reconciler.discover()
+	assert.Equal(t, 1, reconciler.queue.Len())
+
+	item, _ := reconciler.queue.Get()
+	assert.Equal(t, "crdkind11", item)
+	reconciler.queue.Done(item)
+	reconciler.forget("crdkind11")
+
+	reconciler.discover()
+	assert.Equal(t, 1, reconciler.queue.Len())
+}
+
+// classCustomResource is a custom resource with a controller class field.
+type classCustomResource struct {
+	*fake.CustomResourceImpl
+	class string
+}
+
+func (c *classCustomResource) GetControllerClass() string {
+	return c.class
+}
+
+func TestClaims(t *testing.T) {
+	unclassified := &fake.CustomResourceImpl{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "crdkind11",
+			Labels: map[string]string{"track": "stable"},
+		},
+	}
+	annotated := &fake.CustomResourceImpl{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "crdkind12",
+			Labels:      map[string]string{"track": "canary"},
+			Annotations: map[string]string{ControllerClassAnnotation: "canary"},
+		},
+	}
+	classified := &classCustomResource{
+		CustomResourceImpl: &fake.CustomResourceImpl{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "crdkind13",
+				Annotations: map[string]string{ControllerClassAnnotation: "stable"},
+			},
+		},
+		class: "canary",
+	}
+
+	stable := &Reconciler{}
+	assert.True(t, stable.claims(unclassified))
+	assert.False(t, stable.claims(annotated))
+	assert.False(t, stable.claims(classified))
+
+	canary := &Reconciler{options: Options{ControllerClass: "canary"}}
+	assert.False(t, canary.claims(unclassified))
+	assert.True(t, canary.claims(annotated))
+	assert.True(t, canary.claims(classified))
+
+	labelled := &Reconciler{options: Options{Labels: map[string]string{"track": "stable"}}}
+	assert.True(t, labelled.claims(unclassified))
+
+	labelledCanary := &Reconciler{options: Options{
+		Labels:          map[string]string{"track": "stable"},
+		ControllerClass: "canary",
+	}}
+	assert.False(t, labelledCanary.claims(annotated))
+}
+
+func TestWithTimeout(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	subresourceClient := &rf.SubresourceClient{PluralValue: "pods"}
+	reconciler := NewWithOptions("namespace1", gvk, nil, &fake.ClientImpl{}, []Registration{{Client: subresourceClient}}, Options{Timeout: time.Hour})
+	defer reconciler.queue.ShutDown()
+
+	// Without Run, passes derive their context from the background context.
+	pass, cancel := reconciler.withTimeout()
+	deadline, ok := pass.ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+	assert.Len(t, pass.registrations, 1)
+	cancel()
+	assert.Error(t, pass.ctx.Err())
+
+	// Cancelling the context supplied to Run cancels the passes in flight.
+	ctx, cancelRun := context.WithCancel(context.Background())
+	reconciler.ctx = ctx
+	pass, cancel = reconciler.withTimeout()
+	defer cancel()
+	cancelRun()
+	<-pass.ctx.Done()
+	assert.Equal(t, context.Canceled, pass.ctx.Err())
+}
+
+// createRecordingClient is a subresource client that records the template
+// values its subresources are created with.
+type createRecordingClient struct {
+	*rf.SubresourceClient
+	templateValues interface{}
+}
+
+func (c *createRecordingClient) Create(namespace string, templateValues interface{}) error {
+	c.templateValues = templateValues
+	return nil
+}
+
+func TestExecuteActionCreateSetsOwnerKind(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	podClient := &createRecordingClient{
+		SubresourceClient: &rf.SubresourceClient{PluralValue: "pods"},
+	}
+	reconciler := &Reconciler{
+		namespace: "namespace1",
+		gvk:       gvk,
+		crdHandle: &crd.Handle{Plural: "crdkind1s"},
+	}
+	cr := &fake.CustomResourceImpl{
+		ObjectMeta: metav1.ObjectMeta{Name: "crdkind11", UID: "3982"},
+	}
+
+	errs := reconciler.executeAction("crdkind11", cr, &action{
+		subresourcesToCreate: subresources{{client: podClient, lifecycle: doesNotExist}},
+	})
+	assert.Empty(t, errs)
+
+	owner, ok := podClient.templateValues.(*fake.CustomResourceImpl)
+	assert.True(t, ok)
+	assert.Equal(t, gvk, owner.GroupVersionKind())
+	assert.Equal(t, "crdkind11", owner.Name())
+	// The custom resource itself is left alone.
+	assert.True(t, cr.GroupVersionKind().Empty())
+}
+
+func TestNamingStrategies(t *testing.T) {
+	assert.Equal(t, "cr1", CustomResourceName.SubresourceName("cr1"))
+	assert.Equal(t, "cr1-worker", SuffixName("worker").SubresourceName("cr1"))
+	assert.Equal(t, "cr1-worker", HashedName("worker").SubresourceName("cr1"))
+
+	long := strings.Repeat("a", 60)
+	hashed := HashedName("worker").SubresourceName(long)
+	assert.Len(t, hashed, 63)
+	assert.NotEqual(t, hashed, HashedName("worker").SubresourceName(long+"b"))
+
+	templated, err := TemplateName("{{.Name}}-ps")
+	assert.NoError(t, err)
+	assert.Equal(t, "cr1-ps", templated.SubresourceName("cr1"))
+
+	_, err = TemplateName("{{.Name")
+	assert.Error(t, err)
+	// Parses, but fails to execute.
+	_, err = TemplateName("{{.Namespace}}-ps")
+	assert.Error(t, err)
+
+	// Fails to execute for some names only. Those subresources are skipped
+	// rather than named after the custom resource.
+	partial, err := TemplateName(`{{if eq .Name "cr2"}}{{.Namespace}}{{end}}{{.Name}}-ps`)
+	assert.NoError(t, err)
+	assert.Equal(t, "cr1-ps", partial.SubresourceName("cr1"))
+	assert.Equal(t, "", partial.SubresourceName("cr2"))
+	registration := Registration{Client: &rf.SubresourceClient{}, Naming: partial, Count: 2}
+	assert.Equal(t, []string{"cr1-ps-0", "cr1-ps-1"}, registration.memberNames("cr1"))
+	assert.Empty(t, registration.memberNames("cr2"))
+}
+
+// namedClient is a subresource client that records the names its
+// subresources are created with.
+type namedClient struct {
+	*rf.SubresourceClient
+	name string
+}
+
+func (c *namedClient) CreateNamed(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	c.name = name
+	return nil, nil
+}
+
+func TestCreateSubresourceNaming(t *testing.T) {
+	workerClient := &namedClient{
+		SubresourceClient: &rf.SubresourceClient{PluralValue: "pods"},
+	}
+	serviceClient := &rf.SubresourceClient{PluralValue: "services"}
+	reconciler := NewWithRegistrations("namespace1", schema.GroupVersionKind{}, nil, &fake.ClientImpl{}, []Registration{
+		{Client: workerClient, Naming: SuffixName("worker")},
+		{Client: serviceClient, Naming: SuffixName("svc")},
+	})
+	defer reconciler.queue.ShutDown()
+
+	subs := subresourceMap{}
+	reconciler.addMissingSubresources(subs, []runtime.Object{
+		&fake.CustomResourceImpl{ObjectMeta: metav1.ObjectMeta{Name: "cr1"}},
+	})
+	assert.Len(t, subs["cr1"], 2)
+
+	assert.NoError(t, reconciler.createSubresource(subs["cr1"][0], "cr1", nil))
+	assert.Equal(t, "cr1-worker", workerClient.name)
+
+	// Clients that cannot name their subresources fail.
+	assert.Error(t, reconciler.createSubresource(subs["cr1"][1], "cr1", nil))
+
+	_, err := reconciler.GetSubresource("configmaps", "cr1")
+	assert.Error(t, err)
+}
+
+// memberStore holds the subresources of one kind, shared by the clients
+// registered for that kind.
+type memberStore struct {
+	objects map[string]*rf.Subresource
+	lists   int
+	gets    int
+	created []string
+	deleted []string
+	patches []string
+}
+
+// memberClient is a subresource client that keeps the subresources it
+// creates in its store, so that they are listed by later passes.
+type memberClient struct {
+	*rf.SubresourceClient
+	store *memberStore
+}
+
+func newMemberClient(store *memberStore) *memberClient {
+	return &memberClient{
+		SubresourceClient: &rf.SubresourceClient{
+			Subresource: &rf.Subresource{Ephemeral: true, StatusState: states.Pending},
+			PluralValue: "pods",
+		},
+		store: store,
+	}
+}
+
+func (c *memberClient) WithContext(ctx context.Context) resource.Client {
+	return c
+}
+
+func (c *memberClient) CreateNamed(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	owner := templateValues.(runtime.Object)
+	ownerMeta, err := meta.Accessor(owner)
+	if err != nil {
+		return nil, err
+	}
+	gvk := owner.GetObjectKind().GroupVersionKind()
+	controller := true
+	obj := &rf.Subresource{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{resource.InstanceLabel: ownerMeta.GetName()},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: gvk.GroupVersion().String(),
+				Kind:       gvk.Kind,
+				Name:       ownerMeta.GetName(),
+				UID:        ownerMeta.GetUID(),
+				Controller: &controller,
+			}},
+		},
+		StatusState: states.Running,
+	}
+	c.store.objects[name] = obj
+	c.store.created = append(c.store.created, name)
+	return obj, nil
+}
+
+func (c *memberClient) Get(namespace string, name string) (runtime.Object, error) {
+	c.store.gets++
+	obj, ok := c.store.objects[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+	}
+	return obj, nil
+}
+
+// PatchObject merges the annotations of the supplied merge patch into the
+// stored subresource.
+func (c *memberClient) PatchObject(namespace string, name string, patchType types.PatchType, data []byte) (runtime.Object, error) {
+	c.store.patches = append(c.store.patches, string(data))
+	obj, ok := c.store.objects[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, name)
+	}
+	patch := struct {
+		Metadata struct {
+			Annotations map[string]*string `json:"annotations"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	for key, value := range patch.Metadata.Annotations {
+		if value == nil {
+			delete(obj.Annotations, key)
+			continue
+		}
+		if obj.Annotations == nil {
+			obj.Annotations = map[string]string{}
+		}
+		obj.Annotations[key] = *value
+	}
+	return obj, nil
+}
+
+func (c *memberClient) Delete(namespace string, name string) error {
+	c.store.deleted = append(c.store.deleted, name)
+	delete(c.store.objects, name)
+	return nil
+}
+
+func (c *memberClient) List(namespace string, labels map[string]string) ([]metav1.Object, error) {
+	c.store.lists++
+	var names []string
+	for name, obj := range c.store.objects {
+		if apilabels.SelectorFromSet(labels).Matches(apilabels.Set(obj.Labels)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var result []metav1.Object
+	for _, name := range names {
+		result = append(result, c.store.objects[name])
+	}
+	return result, nil
+}
+
+func TestReconcileMemberGroups(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	cr := &fake.CustomResourceImpl{
+		ObjectMeta:  metav1.ObjectMeta{Name: "cr1", UID: "3982"},
+		SpecState:   states.Running,
+		StatusState: states.Pending,
+	}
+	controller := true
+	store := &memberStore{objects: map[string]*rf.Subresource{
+		// The parameter server was created without the instance label, and
+		// its template sets the standard instance label to another value.
+		"cr1-ps": {
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "cr1-ps",
+				Namespace: "namespace1",
+				Labels:    map[string]string{"app.kubernetes.io/instance": "release1"},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "kubernetes.intel.com/v1",
+					Kind:       "CRDKind1",
+					Name:       "cr1",
+					UID:        "3982",
+					Controller: &controller,
+				}},
+			},
+			StatusState: states.Running,
+		},
+	}}
+	// Workers and parameter servers are both pods.
+	reconciler := NewWithRegistrations("namespace1", gvk, &crd.Handle{Plural: "crdkind1s"}, &fake.ClientImpl{
+		CustomResourceImpl:     cr,
+		CustomResourceListImpl: &fake.CustomResourceListImpl{Items: []fake.CustomResourceImpl{*cr}},
+	}, []Registration{
+		{Name: "workers", Client: newMemberClient(store), Count: 3, Naming: SuffixName("worker"), Quorum: Quorum{MinRunning: 2}},
+		{Name: "ps", Client: newMemberClient(store), Naming: SuffixName("ps")},
+		// Registrations are unique by name.
+		{Name: "ps", Client: newMemberClient(store)},
+	})
+	defer reconciler.queue.ShutDown()
+	assert.Len(t, reconciler.registrations, 2)
+
+	memberNames := func() []string {
+		var names []string
+		for name := range store.objects {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	allMembers := []string{"cr1-ps", "cr1-worker-0", "cr1-worker-1", "cr1-worker-2"}
+
+	// The custom resource was not discovered yet, so the first pass lists
+	// the pods once, and creates every missing member under its own name.
+	_, ok := reconciler.reconcile("cr1")
+	assert.True(t, ok)
+	assert.Equal(t, allMembers, memberNames())
+	assert.Equal(t, []string{"cr1-worker-0", "cr1-worker-1", "cr1-worker-2"}, store.created)
+	assert.Equal(t, 1, store.lists)
+	assert.Equal(t, states.Pending, cr.StatusState)
+
+	// Once discovered, each member is got by name rather than listed again,
+	// and the groups are complete.
+	reconciler.discover()
+	assert.Equal(t, 2, store.lists)
+	store.gets = 0
+	subs := reconciler.subresourcesFor("cr1")
+	assert.Equal(t, 2, store.lists)
+	assert.Equal(t, 4, store.gets)
+	assert.Len(t, subs, 4)
+	groups := subs.byRegistration()
+	assert.Len(t, groups["workers"], 3)
+	assert.Len(t, groups["ps"], 1)
+
+	reconciler.reconcile("cr1")
+	assert.Equal(t, states.Running, cr.StatusState)
+	assert.Equal(t, allMembers, memberNames())
+
+	// A pending worker is tolerated by the quorum.
+	store.objects["cr1-worker-1"].StatusState = states.Pending
+	reconciler.reconcile("cr1")
+	assert.Equal(t, states.Running, cr.StatusState)
+
+	// A failed worker fails the custom resource.
+	store.objects["cr1-worker-0"].StatusState = states.Failed
+	reconciler.reconcile("cr1")
+	assert.Equal(t, states.Failed, cr.StatusState)
+
+	// Every member is deleted by its own name.
+	reconciler.reconcile("cr1")
+	sort.Strings(store.deleted)
+	assert.Equal(t, allMembers, store.deleted)
+	assert.Empty(t, store.objects)
+}
+
+func TestControllerName(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	controller := true
+	retainedLabels := map[string]string{
+		resource.ManagedByLabel: resource.ManagedBy,
+		resource.PartOfLabel:    "crdkind1s",
+		resource.InstanceLabel:  "cr1",
+	}
+	retained := map[string]string{resource.RetainAnnotation: "true"}
+	tests := map[string]struct {
+		objectMeta metav1.ObjectMeta
+		name       string
+		ok         bool
+	}{
+		"controlled": {
+			objectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "kubernetes.intel.com/v1", Kind: "CRDKind1", Name: "cr1", Controller: &controller,
+			}}},
+			name: "cr1",
+			ok:   true,
+		},
+		"controlled by another kind": {
+			objectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "kubernetes.intel.com/v1", Kind: "CRDKind2", Name: "cr1", Controller: &controller,
+			}}},
+		},
+		"retained": {
+			objectMeta: metav1.ObjectMeta{Labels: retainedLabels, Annotations: retained},
+			name:       "cr1",
+			ok:         true,
+		},
+		"retained by another kind": {
+			objectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					resource.ManagedByLabel: resource.ManagedBy,
+					resource.PartOfLabel:    "crdkind2s",
+					resource.InstanceLabel:  "cr1",
+				},
+				Annotations: retained,
+			},
+		},
+		"released": {
+			objectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					resource.ManagedByLabel: resource.ManagedBy,
+					resource.PartOfLabel:    "crdkind1s",
+				},
+				Annotations: retained,
+			},
+		},
+		"labelled but not retained": {
+			objectMeta: metav1.ObjectMeta{Labels: retainedLabels},
+		},
+	}
+
+	r := &Reconciler{namespace: "namespace1", gvk: gvk, crdHandle: &crd.Handle{Plural: "crdkind1s"}}
+	for testName, tc := range tests {
+		name, ok := r.controllerName(&rf.Subresource{ObjectMeta: tc.objectMeta})
+		assert.Equal(t, tc.name, name, testName)
+		assert.Equal(t, tc.ok, ok, testName)
+	}
+}
+
+// configClient is a member client whose template reifies to the "config"
+// annotation of the custom resource, and that stores the template checksum
+// like the resource clients do.
+type configClient struct {
+	*memberClient
+	// applyError is returned by Apply, e.g. by API servers that do not
+	// support server-side apply.
+	applyError error
+	updated    []string
+}
+
+func (c *configClient) WithContext(ctx context.Context) resource.Client {
+	return c
+}
+
+func (c *configClient) Reify(templateValues interface{}) ([]byte, error) {
+	ownerMeta, err := meta.Accessor(templateValues)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(ownerMeta.GetAnnotations()["config"]), nil
+}
+
+func (c *configClient) withChecksum(obj *rf.Subresource, templateValues interface{}) error {
+	body, err := c.Reify(templateValues)
+	if err != nil {
+		return err
+	}
+	if obj.Annotations == nil {
+		obj.Annotations = map[string]string{}
+	}
+	obj.Annotations[resource.TemplateChecksumAnnotation] = resource.TemplateChecksum(body)
+	return nil
+}
+
+func (c *configClient) CreateNamed(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	obj, err := c.memberClient.CreateNamed(namespace, name, templateValues)
+	if err != nil {
+		return nil, err
+	}
+	return obj, c.withChecksum(obj.(*rf.Subresource), templateValues)
+}
+
+func (c *configClient) Apply(namespace string, name string, templateValues interface{}, opts resource.ApplyOptions) (runtime.Object, error) {
+	if c.applyError != nil {
+		return nil, c.applyError
+	}
+	c.updated = append(c.updated, "apply "+opts.FieldManager+" "+name)
+	obj := c.store.objects[name]
+	return obj, c.withChecksum(obj, templateValues)
+}
+
+func (c *configClient) UpdateObject(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	c.updated = append(c.updated, "update "+name)
+	obj := c.store.objects[name]
+	return obj, c.withChecksum(obj, templateValues)
+}
+
+func TestReconcileTemplateChange(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	cr := &fake.CustomResourceImpl{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cr1",
+			UID:         "3982",
+			Annotations: map[string]string{"config": "a"},
+		},
+		SpecState:   states.Running,
+		StatusState: states.Pending,
+	}
+	store := &memberStore{objects: map[string]*rf.Subresource{}}
+	client := &configClient{memberClient: newMemberClient(store)}
+	reconciler := NewWithOptions("namespace1", gvk, &crd.Handle{Plural: "crdkind1s"}, &fake.ClientImpl{CustomResourceImpl: cr}, []Registration{
+		{Client: client, Naming: SuffixName("deployment")},
+	}, Options{Apply: resource.ApplyOptions{FieldManager: "manager1"}})
+	defer reconciler.queue.ShutDown()
+
+	checksum := func() string {
+		return store.objects["cr1-deployment"].Annotations[resource.TemplateChecksumAnnotation]
+	}
+
+	reconciler.reconcile("cr1")
+	reconciler.reconcile("cr1")
+	assert.Equal(t, states.Running, cr.StatusState)
+	assert.Equal(t, resource.TemplateChecksum([]byte("a")), checksum())
+
+	// An unchanged template is left alone.
+	reconciler.reconcile("cr1")
+	assert.Empty(t, client.updated)
+
+	// A config change applies the subresource and its checksum.
+	cr.Annotations["config"] = "b"
+	reconciler.reconcile("cr1")
+	assert.Equal(t, []string{"apply manager1 cr1-deployment"}, client.updated)
+	assert.Equal(t, resource.TemplateChecksum([]byte("b")), checksum())
+
+	reconciler.reconcile("cr1")
+	assert.Len(t, client.updated, 1)
+
+	// Without server-side apply, the subresource is updated instead.
+	client.applyError = apierrors.NewGenericServerResponse(http.StatusUnsupportedMediaType, "patch", schema.GroupResource{Resource: "pods"}, "cr1-deployment", "", 0, false)
+	cr.Annotations["config"] = "c"
+	reconciler.reconcile("cr1")
+	assert.Equal(t, []string{"apply manager1 cr1-deployment", "update cr1-deployment"}, client.updated)
+	assert.Equal(t, resource.TemplateChecksum([]byte("c")), checksum())
+}
+
+func TestOwner(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	cr := &fake.CustomResourceImpl{
+		ObjectMeta: metav1.ObjectMeta{Name: "cr1", Labels: map[string]string{"app": "app1"}},
+	}
+	r := &Reconciler{gvk: gvk, crdHandle: &crd.Handle{Plural: "crdkind1s"}}
+
+	owner := r.owner(cr)
+	ownerMeta, err := meta.Accessor(owner)
+	assert.NoError(t, err)
+	assert.Equal(t, gvk, owner.GetObjectKind().GroupVersionKind())
+	assert.Equal(t, map[string]string{"app": "app1", resource.PartOfLabel: "crdkind1s"}, ownerMeta.GetLabels())
+	// The custom resource itself is left alone.
+	assert.Equal(t, map[string]string{"app": "app1"}, cr.Labels)
+}
+
+func TestExecuteActionUpdateInvalid(t *testing.T) {
+	invalid := apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "cr1-pod", nil)
+	tests := map[string]struct {
+		ephemeral bool
+		deleted   []string
+		patches   int
+		errors    int
+	}{
+		"ephemeral subresources are recreated": {
+			ephemeral: true,
+			deleted:   []string{"cr1-pod"},
+		},
+		"non-ephemeral subresources are left alone": {
+			ephemeral: false,
+			patches:   1,
+			errors:    1,
+		},
+	}
+	for name, tc := range tests {
+		store := &memberStore{objects: map[string]*rf.Subresource{
+			"cr1-pod": {ObjectMeta: metav1.ObjectMeta{Name: "cr1-pod"}},
+		}}
+		client := &configClient{memberClient: newMemberClient(store), applyError: invalid}
+		client.Subresource.(*rf.Subresource).Ephemeral = tc.ephemeral
+		r := &Reconciler{namespace: "namespace1", crdHandle: &crd.Handle{Plural: "crdkind1s"}}
+		cr := &fake.CustomResourceImpl{ObjectMeta: metav1.ObjectMeta{Name: "cr1"}}
+
+		errs := r.executeAction("cr1", cr, &action{subresourcesToUpdate: subresources{
+			{client: client, lifecycle: exists, name: "cr1-pod"},
+		}})
+		assert.Len(t, errs, tc.errors, name)
+		assert.Equal(t, tc.deleted, store.deleted, name)
+		// The rejected template of non-ephemeral subresources is recorded.
+		assert.Len(t, store.patches, tc.patches, name)
+	}
+}
+
+func TestReconcileTemplateRejected(t *testing.T) {
+	gvk := schema.GroupVersionKind{
+		Group:   "kubernetes.intel.com",
+		Version: "v1",
+		Kind:    "CRDKind1",
+	}
+	cr := &fake.CustomResourceImpl{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "cr1",
+			UID:         "3982",
+			Annotations: map[string]string{"config": "a"},
+		},
+		SpecState:   states.Running,
+		StatusState: states.Pending,
+	}
+	store := &memberStore{objects: map[string]*rf.Subresource{}}
+	client := &configClient{memberClient: newMemberClient(store)}
+	client.Subresource.(*rf.Subresource).Ephemeral = false
+	reconciler := NewWithOptions("namespace1", gvk, &crd.Handle{Plural: "crdkind1s"}, &fake.ClientImpl{CustomResourceImpl: cr}, []Registration{
+		{Client: client, Naming: SuffixName("job")},
+	}, Options{Apply: resource.ApplyOptions{FieldManager: "manager1"}})
+	defer reconciler.queue.ShutDown()
+
+	rejected := func() string {
+		return store.objects["cr1-job"].Annotations[resource.RejectedTemplateChecksumAnnotation]
+	}
+	templateRejected := func() bool {
+		subs := subresources{{client: client, lifecycle: exists, name: "cr1-job", object: store.objects["cr1-job"]}}
+		return reconciler.subresourceStatuses(subs)[0].TemplateRejected
+	}
+
+	// Non-ephemeral subresources are not created by the reconciler.
+	_, err := client.CreateNamed("namespace1", "cr1-job", reconciler.owner(cr))
+	assert.NoError(t, err)
+	reconciler.reconcile("cr1")
+	assert.Equal(t, states.Running, cr.StatusState)
+	assert.False(t, templateRejected())
+
+	// An update rejected by the API server is recorded and reported.
+	client.applyError = apierrors.NewInvalid(schema.GroupKind{Kind: "Job"}, "cr1-job", nil)
+	cr.Annotations["config"] = "b"
+	reconciler.reconcile("cr1")
+	assert.Equal(t, resource.TemplateChecksum([]byte("b")), rejected())
+	assert.Len(t, store.patches, 1)
+	assert.True(t, templateRejected())
+
+	// The rejected template is not retried.
+	reconciler.reconcile("cr1")
+	assert.Len(t, store.patches, 1)
+
+	// Another template change is applied and clears the rejection.
+	client.applyError = nil
+	cr.Annotations["config"] = "c"
+	reconciler.reconcile("cr1")
+	assert.Equal(t, []string{"apply manager1 cr1-job"}, client.updated)
+	assert.Equal(t, resource.TemplateChecksum([]byte("c")), store.objects["cr1-job"].Annotations[resource.TemplateChecksumAnnotation])
+	assert.Empty(t, rejected())
+	assert.False(t, templateRejected())
+
+	// So does changing the template back after a rejection.
+	client.applyError = apierrors.NewInvalid(schema.GroupKind{Kind: "Job"}, "cr1-job", nil)
+	cr.Annotations["config"] = "d"
+	reconciler.reconcile("cr1")
+	assert.Equal(t, resource.TemplateChecksum([]byte("d")), rejected())
+	client.applyError = nil
+	cr.Annotations["config"] = "c"
+	reconciler.reconcile("cr1")
+	assert.Empty(t, rejected())
+	reconciler.reconcile("cr1")
+	assert.Len(t, client.updated, 2)
+}
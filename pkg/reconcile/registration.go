@@ -0,0 +1,147 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package reconcile
+
+import (
+	"fmt"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// Quorum describes how many of the subresources managed by a single
+// resource client must be running, or may fail, before the controlling
+// custom resource changes state. The zero value requires all subresources
+// to be running and tolerates no failures.
+type Quorum struct {
+	// MinRunning is the number of subresources that must be running for
+	// the custom resource to be considered running. Zero means all of them.
+	MinRunning int
+	// MaxFailed is the number of failed subresources that are tolerated
+	// before the custom resource is considered failed.
+	MaxFailed int
+}
+
+// Registration associates a resource client with the policy the reconciler
+// applies to the subresources it manages.
+type Registration struct {
+	// Name uniquely identifies the registration, e.g. to register several
+	// clients for the same kind. Empty means the plural form of the client.
+	Name   string
+	Client resource.Client
+	Quorum Quorum
+	// Count is the number of subresources the reconciler maintains for each
+	// custom resource. Zero means one. When there are several, the name
+	// chosen by the naming strategy is followed by "-<index>".
+	Count int
+	// Optional subresources are recreated like any other, but their state
+	// is ignored when deciding the state of the custom resource.
+	Optional bool
+	// Naming chooses the names of the subresources. Nil names them after
+	// their custom resource.
+	Naming NamingStrategy
+}
+
+// name returns the name of the registration.
+func (reg Registration) name() string {
+	if reg.Name != "" {
+		return reg.Name
+	}
+	return reg.Client.Plural()
+}
+
+// count returns the number of subresources maintained for each custom
+// resource.
+func (reg Registration) count() int {
+	if reg.Count < 1 {
+		return 1
+	}
+	return reg.Count
+}
+
+// memberNames returns the names of the subresources maintained for the
+// custom resource with the supplied name, or none if the naming strategy
+// can't name them.
+func (reg Registration) memberNames(crName string) []string {
+	naming := reg.Naming
+	if naming == nil {
+		naming = CustomResourceName
+	}
+	name := naming.SubresourceName(crName)
+	if name == "" {
+		return nil
+	}
+	if reg.count() == 1 {
+		return []string{name}
+	}
+	var result []string
+	for i := 0; i < reg.count(); i++ {
+		result = append(result, fmt.Sprintf("%s-%d", name, i))
+	}
+	return result
+}
+
+// QuorumCustomResource is implemented by custom resources that declare
+// their own quorum for some or all of their subresources. It takes
+// precedence over the quorum supplied in the client registration.
+type QuorumCustomResource interface {
+	// GetQuorum returns the quorum for the subresources of the registration
+	// with the supplied name, which defaults to the plural form of its
+	// client, and false if the registered quorum should be used.
+	GetQuorum(plural string) (Quorum, bool)
+}
+
+// SubresourceStatus is the observed state of a single subresource.
+type SubresourceStatus struct {
+	Plural   string
+	Name     string
+	State    states.State
+	Optional bool
+	// Reason explains the state, if the client implements
+	// resource.StatusReasonClient.
+	Reason string
+	// Progress describes the progress of the subresource, e.g. "3/8
+	// completions", if the client implements resource.ProgressClient.
+	Progress string
+	// JobHistory is the history of the jobs spawned by the subresource, if
+	// its client implements resource.JobHistoryClient.
+	JobHistory *resource.JobHistory
+	// TemplateRejected is true if the API server rejected the update of the
+	// subresource to its current template, e.g. because the template
+	// changes an immutable field of a job. The subresource is left as it
+	// is until its template changes again.
+	TemplateRejected bool
+}
+
+// SubresourceStatusCustomResource is implemented by custom resources that
+// surface the state of their subresources in their own status.
+type SubresourceStatusCustomResource interface {
+	// SetSubresourceStatuses records the supplied subresource states and
+	// returns true if the custom resource status changed as a result.
+	SetSubresourceStatuses([]SubresourceStatus) bool
+}
+
+// registrationsFor returns default registrations for the supplied clients.
+func registrationsFor(clients []resource.Client) []Registration {
+	var result []Registration
+	for _, client := range clients {
+		result = append(result, Registration{Client: client})
+	}
+	return result
+}
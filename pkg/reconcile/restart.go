@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// RestartPolicy describes when the subresources of a custom resource are
+// recreated after it reaches a terminal state.
+type RestartPolicy string
+
+const (
+	// RestartPolicyNever leaves the custom resource in its terminal state.
+	RestartPolicyNever RestartPolicy = "Never"
+
+	// RestartPolicyOnFailure restarts the custom resource when it fails.
+	RestartPolicyOnFailure RestartPolicy = "OnFailure"
+
+	// RestartPolicyAlways restarts the custom resource when it fails or
+	// completes.
+	RestartPolicyAlways RestartPolicy = "Always"
+)
+
+var (
+	// restartBackoffBase is the delay before the first restart. It doubles
+	// for every subsequent restart.
+	restartBackoffBase = 10 * time.Second
+
+	// restartBackoffMax is the upper bound of the delay before a restart.
+	restartBackoffMax = 5 * time.Minute
+)
+
+// RestartableCustomResource is implemented by custom resources that declare
+// a restart policy. The restart count and the time the current restart
+// began are stored in the custom resource status so that they survive
+// reconciler restarts.
+type RestartableCustomResource interface {
+	// GetRestartPolicy returns the restart policy and the maximum number
+	// of restarts.
+	GetRestartPolicy() (RestartPolicy, int)
+	// GetRestartCount returns the number of restarts so far.
+	GetRestartCount() int
+	// GetRestartTime returns the time the current restart began, or nil if
+	// no restart is in progress.
+	GetRestartTime() *metav1.Time
+	// SetRestartStatus records the number of restarts and the time the
+	// current restart began.
+	SetRestartStatus(count int, restartTime *metav1.Time)
+}
+
+// restartStatus is the restart status to record on the custom resource.
+type restartStatus struct {
+	count int
+	time  *metav1.Time
+}
+
+// shouldRestart returns true if the supplied custom resource should be
+// restarted instead of moving to the supplied terminal state.
+func shouldRestart(rcr RestartableCustomResource, terminal states.State) bool {
+	policy, maxRestarts := rcr.GetRestartPolicy()
+	if rcr.GetRestartCount() >= maxRestarts {
+		return false
+	}
+	switch policy {
+	case RestartPolicyAlways:
+		return terminal.IsOneOf(states.Completed, states.Failed)
+	case RestartPolicyOnFailure:
+		return terminal == states.Failed
+	}
+	return false
+}
+
+// restartBackoff returns the delay before the supplied restart attempt.
+func restartBackoff(attempt int) time.Duration {
+	backoff := restartBackoffBase
+	for i := 1; i < attempt && backoff < restartBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > restartBackoffMax {
+		return restartBackoffMax
+	}
+	return backoff
+}
+
+// beginRestart returns an action that moves the supplied custom resource
+// back to pending and deletes all of its subresources instead of moving it
+// to the supplied terminal state.
+func beginRestart(rcr RestartableCustomResource, subs subresources, terminal states.State) *action {
+	_, maxRestarts := rcr.GetRestartPolicy()
+	count := rcr.GetRestartCount() + 1
+	now := metav1.Now()
+	return &action{
+		newCRState:  states.Pending,
+		newCRReason: fmt.Sprintf("restarting after %s (%d/%d)", strings.ToLower(string(terminal)), count, maxRestarts),
+		subresourcesToDelete: subs.filter(func(s *subresource) bool {
+			return s.lifecycle == exists
+		}),
+		restart: &restartStatus{count: count, time: &now},
+	}
+}
+
+// planRestart returns the action for a custom resource with a restart in
+// progress. All subresources are recreated once the previous ones are gone
+// and the backoff for the current attempt has elapsed.
+func planRestart(rcr RestartableCustomResource, subs subresources) *action {
+	remaining := subs.filter(func(s *subresource) bool {
+		return s.lifecycle != doesNotExist
+	})
+	if len(remaining) > 0 {
+		return &action{
+			subresourcesToDelete: remaining.filter(func(s *subresource) bool {
+				return s.lifecycle == exists
+			}),
+		}
+	}
+
+	count := rcr.GetRestartCount()
+	if delay := restartBackoff(count) - time.Since(rcr.GetRestartTime().Time); delay > 0 {
+		return &action{requeueAfter: delay}
+	}
+
+	return &action{
+		subresourcesToCreate: subs,
+		restart:              &restartStatus{count: count},
+	}
+}
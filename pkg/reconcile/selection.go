@@ -0,0 +1,91 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package reconcile
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	apilabels "k8s.io/apimachinery/pkg/labels"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/crd"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource"
+)
+
+// ControllerClassAnnotation is the custom resource annotation that names the
+// class of reconciler responsible for it.
+const ControllerClassAnnotation = "kubernetes.intel.com/controller-class"
+
+// Options selects the custom resources a Reconciler is responsible for.
+// Several reconcilers, e.g. a canary and a stable deployment, can split the
+// custom resources of the same kind between them.
+type Options struct {
+	// Labels selects the custom resources to reconcile. Empty selects all.
+	Labels map[string]string
+	// ControllerClass selects the custom resources of this class. Empty
+	// selects the custom resources without a class.
+	ControllerClass string
+	// Timeout bounds the requests of a single reconcile pass, or of a
+	// single discovery of the custom resources. Zero means one minute.
+	Timeout time.Duration
+	// Apply configures the server-side apply requests that update the
+	// subresources whose template changed, e.g. their field manager.
+	Apply resource.ApplyOptions
+}
+
+// ControllerClassCustomResource is implemented by custom resources that
+// declare their controller class in a field. It takes precedence over the
+// ControllerClassAnnotation.
+type ControllerClassCustomResource interface {
+	// GetControllerClass returns the controller class, or the empty string
+	// if the custom resource has none.
+	GetControllerClass() string
+}
+
+// controllerClassOf returns the controller class of the supplied custom
+// resource.
+func controllerClassOf(cr crd.CustomResource) string {
+	if ccr, ok := cr.(ControllerClassCustomResource); ok {
+		if class := ccr.GetControllerClass(); class != "" {
+			return class
+		}
+	}
+	crMeta, err := meta.Accessor(cr)
+	if err != nil {
+		return ""
+	}
+	return crMeta.GetAnnotations()[ControllerClassAnnotation]
+}
+
+// claims returns true if this reconciler is responsible for the supplied
+// custom resource.
+func (r *Reconciler) claims(cr crd.CustomResource) bool {
+	if controllerClassOf(cr) != r.options.ControllerClass {
+		return false
+	}
+	if len(r.options.Labels) == 0 {
+		return true
+	}
+	crMeta, err := meta.Accessor(cr)
+	if err != nil {
+		return false
+	}
+	selector := apilabels.SelectorFromSet(apilabels.Set(r.options.Labels))
+	return selector.Matches(apilabels.Set(crMeta.GetLabels()))
+}
@@ -0,0 +1,318 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
+)
+
+// baseClient implements the requests that are the same for every kind of
+// resource. Clients embed it and add the evaluation of their status.
+type baseClient struct {
+	globalTemplateValues GlobalTemplateValues
+	restClient           rest.Interface
+	resourcePluralForm   string
+	templateFileName     string
+	// newObject and newList return the empty objects that responses are
+	// decoded into, e.g. a *corev1.Pod and a *corev1.PodList.
+	newObject func() runtime.Object
+	newList   func() runtime.Object
+	// deletePropagation is sent with delete requests. Empty means the
+	// default of the API server.
+	deletePropagation metav1.DeletionPropagation
+	// apiVersion, if not empty, replaces the API version of reified
+	// bodies, e.g. of templates written for an older API version than the
+	// one the client discovered.
+	apiVersion schema.GroupVersion
+	// templateFuncs, if not nil, returns additional template functions for
+	// the supplied template values. Templates that use them are sensitive,
+	// so their reified result is never logged.
+	templateFuncs func(templateValues interface{}) template.FuncMap
+	ctx           context.Context
+}
+
+func (c *baseClient) Reify(templateValues interface{}) ([]byte, error) {
+	var result []byte
+	var err error
+	if c.templateFuncs != nil {
+		result, err = reify.ReifySensitive(c.templateFileName, templateValues, c.globalTemplateValues, c.templateFuncs(templateValues))
+	} else {
+		result, err = reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
+	}
+	if err != nil {
+		return nil, &TemplateError{TemplateFileName: c.templateFileName, Err: err}
+	}
+	return result, nil
+}
+
+// TemplateChecksum returns the checksum that the TemplateChecksumAnnotation
+// of an object reified from the supplied template values holds, without
+// logging the template values or the result.
+func (c *baseClient) TemplateChecksum(templateValues interface{}) (string, error) {
+	var funcs template.FuncMap
+	if c.templateFuncs != nil {
+		funcs = c.templateFuncs(templateValues)
+	}
+	result, err := reify.Render(c.templateFileName, templateValues, c.globalTemplateValues, funcs)
+	if err != nil {
+		return "", &TemplateError{TemplateFileName: c.templateFileName, Err: err}
+	}
+	return TemplateChecksum(result), nil
+}
+
+func (c *baseClient) Create(namespace string, templateValues interface{}) error {
+	_, err := c.CreateObject(namespace, templateValues)
+	return err
+}
+
+func (c *baseClient) CreateObject(namespace string, templateValues interface{}) (runtime.Object, error) {
+	return c.CreateNamed(namespace, "", templateValues)
+}
+
+func (c *baseClient) CreateNamed(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	resourceBody, err := c.body(name, templateValues)
+	if err != nil {
+		return nil, err
+	}
+	return c.create(namespace, name, resourceBody)
+}
+
+// create posts the supplied reified body, setting its name unless empty.
+func (c *baseClient) create(namespace string, name string, resourceBody []byte) (runtime.Object, error) {
+	resourceBody, err := withName(resourceBody, name)
+	if err != nil {
+		return nil, err
+	}
+
+	request := c.restClient.Post().
+		Context(c.ctx).
+		Namespace(namespace).
+		Resource(c.resourcePluralForm).
+		Body(resourceBody)
+
+	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
+
+	result := c.newObject()
+	var statusCode int
+	err = request.Do().StatusCode(&statusCode).Into(result)
+
+	if err != nil {
+		return nil, wrapAPIError("create", c.resourcePluralForm, namespace, name, err)
+	}
+	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
+		return nil, &UnexpectedStatusError{StatusCode: statusCode}
+	}
+	return result, nil
+}
+
+func (c *baseClient) Delete(namespace, name string) error {
+	request := c.restClient.Delete().
+		Context(c.ctx).
+		Namespace(namespace).
+		Resource(c.resourcePluralForm).
+		Name(name)
+	if c.deletePropagation != "" {
+		deletePolicy := c.deletePropagation
+		request = request.Body(&metav1.DeleteOptions{
+			PropagationPolicy: &deletePolicy,
+		})
+	}
+
+	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
+
+	return wrapAPIError("delete", c.resourcePluralForm, namespace, name, request.Do().Error())
+}
+
+func (c *baseClient) Update(namespace string, name string, templateValues interface{}) error {
+	_, err := c.UpdateObject(namespace, name, templateValues)
+	return err
+}
+
+func (c *baseClient) UpdateObject(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	resourceBody, err := c.body(name, templateValues)
+	if err != nil {
+		return nil, err
+	}
+	return c.update(namespace, name, resourceBody)
+}
+
+// update puts the supplied body in place of the object with the supplied
+// name.
+func (c *baseClient) update(namespace string, name string, resourceBody []byte) (runtime.Object, error) {
+	request := c.restClient.Put().
+		Context(c.ctx).
+		Namespace(namespace).
+		Resource(c.resourcePluralForm).
+		Name(name).
+		Body(resourceBody)
+
+	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
+
+	result := c.newObject()
+	var statusCode int
+	err := request.Do().StatusCode(&statusCode).Into(result)
+
+	if err != nil {
+		return nil, wrapAPIError("update", c.resourcePluralForm, namespace, name, err)
+	}
+	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
+		return nil, &UnexpectedStatusError{StatusCode: statusCode}
+	}
+	return result, nil
+}
+
+func (c *baseClient) Patch(namespace string, name string, data []byte) error {
+	_, err := c.PatchObject(namespace, name, types.JSONPatchType, data)
+	return err
+}
+
+func (c *baseClient) PatchObject(namespace string, name string, patchType types.PatchType, data []byte) (runtime.Object, error) {
+	return c.patch(namespace, name, patchType, data, nil)
+}
+
+func (c *baseClient) Apply(namespace string, name string, templateValues interface{}, opts ApplyOptions) (runtime.Object, error) {
+	resourceBody, err := c.body(name, templateValues)
+	if err != nil {
+		return nil, err
+	}
+	return c.patch(namespace, name, ApplyPatchType, resourceBody, opts.params())
+}
+
+// body returns the reified template for the object with the supplied name,
+// owned by the supplied template values. The name is left to the template if
+// empty.
+func (c *baseClient) body(name string, templateValues interface{}) ([]byte, error) {
+	resourceBody, err := c.Reify(templateValues)
+	if err != nil {
+		return nil, err
+	}
+	// The checksum is that of the template as reified, before it is
+	// converted to another API version.
+	resourceBody, err = withOwnership(resourceBody, templateValues)
+	if err != nil {
+		return nil, err
+	}
+	if !c.apiVersion.Empty() {
+		resourceBody, err = withAPIVersion(resourceBody, c.apiVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return withName(resourceBody, name)
+}
+
+func (c *baseClient) patch(namespace string, name string, patchType types.PatchType, data []byte, params map[string]string) (runtime.Object, error) {
+	request := c.restClient.Patch(patchType).
+		Context(c.ctx).
+		Resource(c.resourcePluralForm).
+		Namespace(namespace).
+		Name(name).
+		Body(data)
+	for key, value := range params {
+		request = request.Param(key, value)
+	}
+
+	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
+
+	result := c.newObject()
+	err := request.Do().Into(result)
+	if err != nil {
+		return nil, wrapAPIError("patch", c.resourcePluralForm, namespace, name, err)
+	}
+	return result, nil
+}
+
+func (c *baseClient) Get(namespace, name string) (result runtime.Object, err error) {
+	result = c.newObject()
+	opts := metav1.GetOptions{}
+	err = c.restClient.Get().
+		Context(c.ctx).
+		Namespace(namespace).
+		Resource(c.resourcePluralForm).
+		Name(name).
+		VersionedParams(&opts, metav1.ParameterCodec).
+		Do().
+		Into(result)
+
+	return result, wrapAPIError("get", c.resourcePluralForm, namespace, name, err)
+}
+
+func (c *baseClient) List(namespace string, labels map[string]string) ([]metav1.Object, error) {
+	return listAll(c, namespace, labels)
+}
+
+func (c *baseClient) ListWithOptions(namespace string, opts metav1.ListOptions) (result []metav1.Object, continueToken string, err error) {
+	list := c.newList()
+	err = c.restClient.Get().
+		Context(c.ctx).
+		Namespace(namespace).
+		Resource(c.resourcePluralForm).
+		VersionedParams(&opts, metav1.ParameterCodec).
+		Do().
+		Into(list)
+
+	if err != nil {
+		return []metav1.Object{}, "", wrapAPIError("list", c.resourcePluralForm, namespace, "", err)
+	}
+
+	// The extracted items point into the list, so each one is distinct.
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return []metav1.Object{}, "", err
+	}
+	for _, item := range items {
+		object, err := meta.Accessor(item)
+		if err != nil {
+			return []metav1.Object{}, "", err
+		}
+		result = append(result, object)
+	}
+
+	listMeta, err := meta.ListAccessor(list)
+	if err != nil {
+		return []metav1.Object{}, "", err
+	}
+	return result, listMeta.GetContinue(), nil
+}
+
+func (c *baseClient) GroupVersionResource() schema.GroupVersionResource {
+	return c.restClient.APIVersion().WithResource(c.resourcePluralForm)
+}
+
+func (c *baseClient) ListWatch(namespace string) (cache.ListerWatcher, runtime.Object) {
+	return cache.NewListWatchFromClient(c.restClient, c.resourcePluralForm, namespace, fields.Everything()), c.newObject()
+}
+
+func (c *baseClient) Plural() string {
+	return c.resourcePluralForm
+}
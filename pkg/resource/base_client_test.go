@@ -0,0 +1,88 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+func TestBaseClientTypedResults(t *testing.T) {
+	var deleteBodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Method {
+		case http.MethodDelete:
+			body, _ := ioutil.ReadAll(r.Body)
+			deleteBodies = append(deleteBodies, string(body))
+			json.NewEncoder(w).Encode(&metav1.Status{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+				Status:   metav1.StatusSuccess,
+			})
+		case http.MethodGet:
+			switch r.URL.Path {
+			case "/api/v1/namespaces/namespace1/pods/pod1":
+				json.NewEncoder(w).Encode(&corev1.Pod{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+					ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "namespace1"},
+				})
+			case "/apis/batch/v1/namespaces/namespace1/jobs/job1":
+				json.NewEncoder(w).Encode(&batchv1.Job{
+					TypeMeta:   metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+					ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "namespace1"},
+				})
+			default:
+				t.Errorf("unexpected request %s", r.URL.Path)
+			}
+		}
+	}))
+	defer server.Close()
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	pods := NewPodClient(GlobalTemplateValues{}, clientSet, "pod.yaml")
+	pod, err := pods.Get("namespace1", "pod1")
+	require.NoError(t, err)
+	assert.Equal(t, "pod1", pod.(*corev1.Pod).Name)
+	_, watched := pods.(CacheableClient).ListWatch("namespace1")
+	assert.IsType(t, &corev1.Pod{}, watched)
+
+	jobs := NewJobClient(GlobalTemplateValues{}, clientSet, "job.yaml")
+	job, err := jobs.Get("namespace1", "job1")
+	require.NoError(t, err)
+	assert.Equal(t, "job1", job.(*batchv1.Job).Name)
+
+	// Jobs are deleted in the foreground, so that their pods go first.
+	require.NoError(t, pods.Delete("namespace1", "pod1"))
+	require.NoError(t, jobs.Delete("namespace1", "job1"))
+	require.Len(t, deleteBodies, 2)
+	assert.Empty(t, deleteBodies[0])
+	assert.Contains(t, deleteBodies[1], `"propagationPolicy":"Foreground"`)
+}
@@ -0,0 +1,153 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// CacheableClient is implemented by clients whose objects can be served
+// from an informer cache.
+type CacheableClient interface {
+	// GroupVersionResource identifies the objects managed by the client.
+	GroupVersionResource() schema.GroupVersionResource
+	// ListWatch returns a list watcher for the objects in the supplied
+	// namespace, and an empty object of the watched type.
+	ListWatch(namespace string) (cache.ListerWatcher, runtime.Object)
+}
+
+// informerClient is implemented by clients that look up other objects, such
+// as the pods they control, and can look them up in shared informers too.
+type informerClient interface {
+	// withInformers returns a copy of the client that looks up other
+	// objects in the informers shared through the supplied cache.
+	withInformers(informerCache *informer.Cache) Client
+}
+
+type cachedClient struct {
+	Client
+	cacheable     CacheableClient
+	informerCache *informer.Cache
+}
+
+// NewCachedClient returns a client that serves Get and List from informers
+// shared through the supplied cache. Writes, reads before the informer has
+// synced, and gets of objects missing from the cache go to the supplied
+// client. The pods, jobs and endpoints that clients look up to evaluate the
+// state or progress of their objects are served from shared informers too. Clients that don't
+// implement CacheableClient are otherwise returned unchanged.
+func NewCachedClient(client Client, informerCache *informer.Cache) Client {
+	if ic, ok := client.(informerClient); ok {
+		client = ic.withInformers(informerCache)
+	}
+	cacheable, ok := client.(CacheableClient)
+	if !ok {
+		return client
+	}
+	return &cachedClient{
+		Client:        client,
+		cacheable:     cacheable,
+		informerCache: informerCache,
+	}
+}
+
+// Unwrap returns the client wrapped by a cached client, or the supplied
+// client if it is not cached. Optional interfaces such as
+// StatusReasonClient are implemented by the wrapped client.
+func Unwrap(client Client) Client {
+	if c, ok := client.(*cachedClient); ok {
+		return c.Client
+	}
+	return client
+}
+
+// IsCached returns true if the supplied client is a cached client.
+func IsCached(client Client) bool {
+	_, ok := client.(*cachedClient)
+	return ok
+}
+
+// sharedInformer returns the informer for the supplied namespace, and an
+// empty object of the type it caches.
+func (c *cachedClient) sharedInformer(namespace string) (cache.SharedIndexInformer, runtime.Object) {
+	lw, objType := c.cacheable.ListWatch(namespace)
+	gvr := c.cacheable.GroupVersionResource()
+	key := gvr.String() + "/" + namespace
+	return c.informerCache.Informer(key, lw, objType), objType
+}
+
+func (c *cachedClient) Get(namespace, name string) (runtime.Object, error) {
+	sharedInformer, objType := c.sharedInformer(namespace)
+	if !sharedInformer.HasSynced() {
+		return c.Client.Get(namespace, name)
+	}
+	obj, exists, err := informer.Get(sharedInformer, namespace, name)
+	if err != nil {
+		return objType, err
+	}
+	if !exists {
+		// The informer may not have caught up with an object created
+		// recently.
+		return c.Client.Get(namespace, name)
+	}
+	return obj, nil
+}
+
+func (c *cachedClient) List(namespace string, labels map[string]string) ([]metav1.Object, error) {
+	sharedInformer, _ := c.sharedInformer(namespace)
+	if !sharedInformer.HasSynced() {
+		return c.Client.List(namespace, labels)
+	}
+	objs, err := informer.List(sharedInformer, namespace, labels)
+	if err != nil {
+		return []metav1.Object{}, err
+	}
+
+	var result []metav1.Object
+	for _, obj := range objs {
+		if objMeta, ok := obj.(metav1.Object); ok {
+			result = append(result, objMeta)
+		}
+	}
+	return result, nil
+}
+
+func (c *cachedClient) WithContext(ctx context.Context) Client {
+	return &cachedClient{
+		Client:        c.Client.WithContext(ctx),
+		cacheable:     c.cacheable,
+		informerCache: c.informerCache,
+	}
+}
+
+func (c *cachedClient) IsFailed(namespace string, name string) bool {
+	obj, err := c.Get(namespace, name)
+	if err != nil {
+		return false
+	}
+	return c.Client.GetStatusState(obj) == states.Failed
+}
@@ -0,0 +1,106 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+)
+
+// podServer lists no pods, and serves pod1 to gets.
+func podServer(t *testing.T, gets *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/v1/namespaces/namespace1/pods" && r.URL.Query().Get("watch") == "true":
+			// Watch without events until the informer stops.
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case r.URL.Path == "/api/v1/namespaces/namespace1/pods":
+			json.NewEncoder(w).Encode(&corev1.PodList{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"},
+				ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+			})
+		case r.URL.Path == "/api/v1/namespaces/namespace1/pods/pod1":
+			atomic.AddInt32(gets, 1)
+			json.NewEncoder(w).Encode(&corev1.Pod{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "namespace1"},
+			})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestCachedClientGetMiss(t *testing.T) {
+	var gets int32
+	server := podServer(t, &gets)
+	defer server.Close()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	c := NewCachedClient(NewPodClient(GlobalTemplateValues{}, clientSet, "pod.yaml"), informer.NewCache(0, stopCh))
+	sharedInformer, _ := c.(*cachedClient).sharedInformer("namespace1")
+	require.True(t, cache.WaitForCacheSync(stopCh, sharedInformer.HasSynced))
+
+	// The pod is not in the synced cache yet, e.g. because it was just
+	// created, so it is got from the API server.
+	obj, err := c.Get("namespace1", "pod1")
+	require.NoError(t, err)
+	assert.Equal(t, "pod1", asPod(obj).Name)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&gets))
+}
+
+func TestNewCachedClientInformers(t *testing.T) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	informers := informer.NewCache(0, stopCh)
+	server := discoveryServer("apps/v1", "statefulsets")
+	defer server.Close()
+	config := &rest.Config{Host: server.URL}
+
+	statefulSets, err := NewStatefulSetClient(GlobalTemplateValues{}, config, "statefulset.yaml")
+	require.NoError(t, err)
+	cached := NewCachedClient(statefulSets, informers).WithContext(context.Background())
+	assert.Equal(t, informers, Unwrap(cached).(*statefulSetClient).pods.informers)
+
+	cronJobs, err := NewCronJobClient(GlobalTemplateValues{}, config, "cronjob.yaml")
+	require.NoError(t, err)
+	cached = NewCachedClient(cronJobs, informers)
+	assert.Equal(t, informers, Unwrap(cached).(*cronJobClient).informers)
+}
@@ -19,208 +19,56 @@
 package resource
 
 import (
-	"fmt"
-	"net/http"
+	"context"
 
-	"github.com/golang/glog"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	apilabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
 
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
 type configMapClient struct {
-	globalTemplateValues GlobalTemplateValues
-	restClient           rest.Interface
-	resourcePluralForm   string
-	templateFileName     string
+	baseClient
 }
 
-// configMapClient returns a new pod client.
+// NewConfigMapClient returns a new config map client. The checksum of the
+// config map is available through the ChecksumClient interface, and to other
+// templates through the Checksum template function.
 func NewConfigMapClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, templateFileName string) Client {
 	return &configMapClient{
-		globalTemplateValues: globalTemplateValues,
-		restClient:           clientSet.CoreV1().RESTClient(),
-		resourcePluralForm:   "configmaps",
-		templateFileName:     templateFileName,
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           clientSet.CoreV1().RESTClient(),
+			resourcePluralForm:   "configmaps",
+			templateFileName:     templateFileName,
+			newObject:            func() runtime.Object { return &corev1.ConfigMap{} },
+			newList:              func() runtime.Object { return &corev1.ConfigMapList{} },
+		},
 	}
 }
 
-func (c *configMapClient) Reify(templateValues interface{}) ([]byte, error) {
-	result, err := reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
-}
-
-func (c *configMapClient) Create(namespace string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Post().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
-	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
-	}
-	return nil
-}
-
-func (c *configMapClient) Delete(namespace, name string) error {
-	request := c.restClient.Delete().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name)
-
-	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
-
-	return request.Do().Error()
-}
-
-func (c *configMapClient) Update(namespace string, name string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Put().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
-	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
-	}
-	return nil
-}
-
-func (c *configMapClient) Patch(namespace string, name string, data []byte) error {
-
-	request := c.restClient.Patch(types.JSONPatchType).
-		Resource(c.resourcePluralForm).
-		Namespace(namespace).
-		Name(name).
-		Body(data)
-
-	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
-
-	return request.Do().Error()
-}
-
-func (c *configMapClient) Get(namespace, name string) (result runtime.Object, err error) {
-	result = &corev1.Pod{}
-	opts := metav1.GetOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(result)
-
-	return result, err
-}
-
-func (c *configMapClient) List(namespace string, labels map[string]string) (result []metav1.Object, err error) {
-	list := &corev1.PodList{}
-
-	opts := metav1.ListOptions{}
-	if len(labels) > 0 {
-		selector := apilabels.SelectorFromSet(apilabels.Set(labels))
-		opts = metav1.ListOptions{LabelSelector: selector.String()}
-	}
-
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(list)
-
-	if err != nil {
-		return []metav1.Object{}, err
-	}
-
-	for _, item := range list.Items {
-		// We need a copy of the item here because item has function scope whereas the copy below has a local scope.
-		// Ex: When we iterate through items, the result list will only contain multiple copies of the last item in the list.
-		podCopy := item
-		result = append(result, &podCopy)
-	}
-
-	return
+func (c *configMapClient) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	return &clientCopy
 }
 
 func (c *configMapClient) IsEphemeral() bool {
 	return true
 }
 
-func (c *configMapClient) Plural() string {
-	return c.resourcePluralForm
-}
-
 func (c *configMapClient) IsFailed(namespace string, name string) bool {
-	p, err := c.Get(namespace, name)
-	if err != nil {
-		return false
-	}
-	return c.isFailed(p)
-}
-
-func (c *configMapClient) isFailed(obj runtime.Object) bool {
-	pod, ok := obj.(*corev1.Pod)
-	if !ok {
-		panic("object was not a *corev1.Pod")
-	}
-	if pod.Status.Phase == corev1.PodFailed {
-		return true
-	}
-	for _, status := range pod.Status.ContainerStatuses {
-		if !status.Ready && status.RestartCount > 0 {
-			return true
-		}
-		// Indicates that a container in the pod was terminated with a non-zero exit code
-		if !status.Ready && status.State.Terminated != nil && status.State.Terminated.ExitCode > 0 {
-			return true
-		}
-	}
 	return false
 }
 
 func (c *configMapClient) GetStatusState(obj runtime.Object) states.State {
-	if c.isFailed(obj) {
-		return states.Failed
-	}
-
-	// TODO(CD): Detect Pending, Completed and Failed states.
+	// A config map is usable as soon as it exists. Pending, Completed and
+	// Failed don't make sense for this type.
 	return states.Running
 }
+
+func (c *configMapClient) Checksum(templateValues interface{}) (string, error) {
+	return reify.Checksum(c.templateFileName, templateValues, c.globalTemplateValues)
+}
@@ -19,8 +19,12 @@
 package resource
 
 import (
+	"context"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apilabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
@@ -32,16 +36,40 @@ type Client interface {
 	// Create creates a new object using the supplied data object for
 	// template expansion.
 	Create(namespace string, templateValues interface{}) error
+	// CreateObject is like Create, and returns the object created by the
+	// API server, e.g. with its generated name, UID and resource version.
+	// When the template values are a custom resource, the object gets a
+	// controller reference to it and the standard labels.
+	CreateObject(namespace string, templateValues interface{}) (runtime.Object, error)
 	// Delete deletes the object.
 	Delete(namespace string, name string) error
 	// Update updates the object.
 	Update(namespace string, name string, templateValues interface{}) error
+	// UpdateObject is like Update, and returns the updated object. When the
+	// template values are a custom resource, the object keeps its controller
+	// reference and the standard labels.
+	UpdateObject(namespace string, name string, templateValues interface{}) (runtime.Object, error)
 	// Patch updates the object using JSON patch.
 	Patch(namespace string, name string, data []byte) error
+	// PatchObject updates the object using a patch of the supplied type,
+	// e.g. a JSON merge or strategic merge patch, and returns the patched
+	// object.
+	PatchObject(namespace string, name string, patchType types.PatchType, data []byte) (runtime.Object, error)
+	// Apply applies the object expanded from the supplied template values
+	// using server-side apply, and returns the applied object. Unlike
+	// Update, it leaves the fields managed by other field managers alone.
+	// Like CreateObject, it applies the controller reference and the
+	// standard labels too, so that the field manager keeps owning them.
+	Apply(namespace string, name string, templateValues interface{}, opts ApplyOptions) (runtime.Object, error)
 	// Get retrieves the object.
 	Get(namespace, name string) (runtime.Object, error)
-	// List lists objects based on group, version and kind.
+	// List lists objects based on group, version and kind. Only objects
+	// with the supplied labels are returned.
 	List(namespace string, labels map[string]string) ([]metav1.Object, error)
+	// ListWithOptions lists one page of objects selected by the supplied
+	// options, and returns the token to pass as Continue for the next page.
+	// The token is empty on the last page.
+	ListWithOptions(namespace string, opts metav1.ListOptions) ([]metav1.Object, string, error)
 	// IsFailed returns true if this resource is in a broken state.
 	IsFailed(namespace string, name string) bool
 	// Plural returns the plural form of the resource.
@@ -50,7 +78,147 @@ type Client interface {
 	Plural() string
 	// GetStatusState returns the current status of the resource.
 	GetStatusState(runtime.Object) states.State
+	// WithContext returns a copy of the client whose requests are bound to
+	// the supplied context, e.g. to cancel them or to set a deadline.
+	WithContext(ctx context.Context) Client
+}
+
+// ApplyPatchType is the patch type of server-side apply requests.
+const ApplyPatchType types.PatchType = "application/apply-patch+yaml"
+
+// DefaultFieldManager is the field manager of server-side apply requests
+// that do not name one.
+const DefaultFieldManager = "crd-reconciler"
+
+// ApplyOptions configures server-side apply requests.
+type ApplyOptions struct {
+	// FieldManager names the owner of the applied fields. Empty means
+	// DefaultFieldManager.
+	FieldManager string
+	// Force takes over the fields owned by other field managers instead of
+	// failing with a conflict.
+	Force bool
+}
+
+// params returns the query parameters of a server-side apply request.
+func (opts ApplyOptions) params() map[string]string {
+	fieldManager := opts.FieldManager
+	if fieldManager == "" {
+		fieldManager = DefaultFieldManager
+	}
+	params := map[string]string{"fieldManager": fieldManager}
+	if opts.Force {
+		params["force"] = "true"
+	}
+	return params
+}
+
+// JobRecord describes a job spawned by a subresource.
+type JobRecord struct {
+	Name           string
+	State          states.State
+	StartTime      *metav1.Time
+	CompletionTime *metav1.Time
+}
+
+// JobHistory describes the jobs spawned by a subresource, most recently
+// scheduled first.
+type JobHistory struct {
+	LastScheduleTime *metav1.Time
+	Jobs             []JobRecord
+}
+
+// JobHistoryClient is implemented by clients whose resources spawn jobs,
+// such as cron jobs.
+type JobHistoryClient interface {
+	// GetJobHistory returns the history of the jobs spawned by the object.
+	GetJobHistory(runtime.Object) (JobHistory, error)
+}
+
+// StatusReasonClient is implemented by clients that can explain the status
+// of their resources.
+type StatusReasonClient interface {
+	// GetStatusReason returns why the resource is in its current state, e.g.
+	// why it failed, or an empty string if there is nothing to report.
+	GetStatusReason(runtime.Object) string
+}
+
+// ProgressClient is implemented by clients that can report the progress of
+// their resources.
+type ProgressClient interface {
+	// GetProgress returns a short description of the progress of the
+	// resource, e.g. "3/8 completions".
+	GetProgress(runtime.Object) string
+}
+
+// NamedClient is implemented by clients that can create objects under a name
+// chosen by the caller, e.g. by a naming strategy, rather than the name set
+// in the template.
+type NamedClient interface {
+	// CreateNamed is like CreateObject, and names the created object after
+	// the supplied name unless it is empty.
+	CreateNamed(namespace string, name string, templateValues interface{}) (runtime.Object, error)
+}
+
+// ChecksumClient is implemented by clients whose resources are consumed by
+// other subresources, such as config maps.
+type ChecksumClient interface {
+	// Checksum returns the checksum of the object reified from the supplied
+	// template values. It changes whenever the content of the object does.
+	Checksum(templateValues interface{}) (string, error)
+}
+
+// TemplateChecksumClient is implemented by clients that can tell whether an
+// object was reified from the current template without logging it, as all
+// built-in clients can.
+type TemplateChecksumClient interface {
+	// TemplateChecksum returns the checksum of the object reified from the
+	// supplied template values, as held by its TemplateChecksumAnnotation.
+	TemplateChecksum(templateValues interface{}) (string, error)
 }
 
+// RetainAnnotation is the annotation that, when set to "true" on a
+// subresource that supports it, keeps the subresource when it is deleted by
+// the reconciler. Retained subresources are created without an owner
+// reference, so that the garbage collector keeps them too.
+const RetainAnnotation = "kubernetes.intel.com/retain-on-delete"
+
 // GlobalTemplateValues encodes values which will be available to all template specializations.
 type GlobalTemplateValues map[string]string
+
+// listPageSize is the number of objects requested at a time by List.
+const listPageSize = 500
+
+// listOptionsFor returns list options selecting objects with the supplied
+// labels.
+func listOptionsFor(labels map[string]string) metav1.ListOptions {
+	if len(labels) == 0 {
+		return metav1.ListOptions{}
+	}
+	selector := apilabels.SelectorFromSet(apilabels.Set(labels))
+	return metav1.ListOptions{LabelSelector: selector.String()}
+}
+
+// pageLister lists one page of objects at a time.
+type pageLister interface {
+	ListWithOptions(namespace string, opts metav1.ListOptions) ([]metav1.Object, string, error)
+}
+
+// listAll lists all objects with the supplied labels, one page at a time.
+func listAll(c pageLister, namespace string, labels map[string]string) ([]metav1.Object, error) {
+	opts := listOptionsFor(labels)
+	opts.Limit = listPageSize
+
+	var result []metav1.Object
+	for {
+		page, continueToken, err := c.ListWithOptions(namespace, opts)
+		if err != nil {
+			return []metav1.Object{}, err
+		}
+		result = append(result, page...)
+		if continueToken == "" {
+			return result, nil
+		}
+		opts.Continue = continueToken
+	}
+}
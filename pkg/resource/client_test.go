@@ -0,0 +1,116 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// pageServer serves the supplied pages of pods, linked by continue tokens
+// that are the index of the next page. A page without pods fails.
+func pageServer(t *testing.T, pages [][]string, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/namespace1/pods", r.URL.Path)
+		assert.Equal(t, "app=a", r.URL.Query().Get("labelSelector"))
+		assert.Equal(t, "500", r.URL.Query().Get("limit"))
+		continueToken := r.URL.Query().Get("continue")
+		*requests = append(*requests, continueToken)
+
+		page := 0
+		if continueToken != "" {
+			page = int(continueToken[0] - '0')
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if len(pages[page]) == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(&metav1.Status{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Status"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonInternalError,
+				Code:     http.StatusInternalServerError,
+			})
+			return
+		}
+		list := &corev1.PodList{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"}}
+		if page+1 < len(pages) {
+			list.Continue = string('0' + byte(page+1))
+		}
+		for _, name := range pages[page] {
+			list.Items = append(list.Items, corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "namespace1"}})
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+}
+
+func TestListAllPages(t *testing.T) {
+	tests := map[string]struct {
+		pages    [][]string
+		expected []string
+		requests []string
+		fails    bool
+	}{
+		"one page": {
+			pages:    [][]string{{"pod1", "pod2"}},
+			expected: []string{"pod1", "pod2"},
+			requests: []string{""},
+		},
+		"two pages": {
+			pages:    [][]string{{"pod1", "pod2"}, {"pod3"}},
+			expected: []string{"pod1", "pod2", "pod3"},
+			requests: []string{"", "1"},
+		},
+		"second page fails": {
+			pages:    [][]string{{"pod1", "pod2"}, {}},
+			requests: []string{"", "1"},
+			fails:    true,
+		},
+	}
+	for name, tc := range tests {
+		var requests []string
+		server := pageServer(t, tc.pages, &requests)
+		clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+		require.NoError(t, err, name)
+		c := NewPodClient(GlobalTemplateValues{}, clientSet, "pod.yaml")
+
+		objs, err := c.List("namespace1", map[string]string{"app": "a"})
+		server.Close()
+		assert.Equal(t, tc.requests, requests, name)
+		if tc.fails {
+			assert.Error(t, err, name)
+			assert.Empty(t, objs, name)
+			continue
+		}
+		require.NoError(t, err, name)
+		var names []string
+		for _, obj := range objs {
+			names = append(names, obj.GetName())
+		}
+		assert.Equal(t, tc.expected, names, name)
+	}
+}
@@ -0,0 +1,168 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/golang/glog"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+type cronJobClient struct {
+	*unstructuredClient
+	k8sClientset kubernetes.Interface
+	informers    *informer.Cache
+}
+
+// NewCronJobClient returns a new cron job client. The status of a cron job
+// is the status of the most recently scheduled job. The history of the jobs
+// it spawned is available through the JobHistoryClient interface.
+func NewCronJobClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string) (Client, error) {
+	c, err := newUnstructuredClient(globalTemplateValues, config, UnstructuredResource{
+		GroupVersionResource: schema.GroupVersionResource{Group: "batch", Version: "v1beta1", Resource: "cronjobs"},
+		Kind:                 "CronJob",
+	}, templateFileName)
+	if err != nil {
+		return nil, err
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &cronJobClient{unstructuredClient: c, k8sClientset: clientSet}, nil
+}
+
+func (c *cronJobClient) WithContext(ctx context.Context) Client {
+	return &cronJobClient{unstructuredClient: c.withContext(ctx), k8sClientset: c.k8sClientset, informers: c.informers}
+}
+
+func (c *cronJobClient) withInformers(informers *informer.Cache) Client {
+	return &cronJobClient{unstructuredClient: c.unstructuredClient, k8sClientset: c.k8sClientset, informers: informers}
+}
+
+func (c *cronJobClient) IsFailed(namespace string, name string) bool {
+	return isFailed(c, namespace, name)
+}
+
+func (c *cronJobClient) GetStatusState(obj runtime.Object) states.State {
+	history, err := c.GetJobHistory(obj)
+	if err != nil {
+		glog.Warningf("[cronjob] failed to get job history: %v", err)
+		return states.Running
+	}
+
+	// A cron job keeps running between schedules, so it only leaves the
+	// running state when the most recently scheduled job failed.
+	if len(history.Jobs) > 0 && history.Jobs[0].State == states.Failed {
+		return states.Failed
+	}
+	return states.Running
+}
+
+func (c *cronJobClient) GetJobHistory(obj runtime.Object) (JobHistory, error) {
+	cronJob := &batchv1beta1.CronJob{}
+	if err := fromUnstructured(obj, cronJob); err != nil {
+		panic(fmt.Sprintf("object was not a cron job: %v", err))
+	}
+
+	history := JobHistory{LastScheduleTime: cronJob.Status.LastScheduleTime}
+
+	// Jobs spawned by a cron job carry the labels of the job template.
+	// Without them, every job in the namespace would be listed.
+	if len(cronJob.Spec.JobTemplate.Labels) == 0 {
+		glog.Warningf("[cronjob] %s/%s has no job template labels to select its jobs by", cronJob.Namespace, cronJob.Name)
+		return history, nil
+	}
+	var jobClient Client = newJobClient(nil, c.k8sClientset.BatchV1().RESTClient(), "").WithContext(c.ctx)
+	if c.informers != nil {
+		jobClient = NewCachedClient(jobClient, c.informers)
+	}
+	jobList, err := jobClient.List(cronJob.ObjectMeta.Namespace, cronJob.Spec.JobTemplate.Labels)
+	if err != nil {
+		return history, err
+	}
+
+	for _, obj := range jobList {
+		controllerRef := metav1.GetControllerOf(obj)
+		if controllerRef == nil || controllerRef.UID != cronJob.UID {
+			continue
+		}
+		job, ok := obj.(*batchv1.Job)
+		if !ok {
+			continue
+		}
+		history.Jobs = append(history.Jobs, JobRecord{
+			Name:           job.Name,
+			State:          jobState(job),
+			StartTime:      job.Status.StartTime,
+			CompletionTime: job.Status.CompletionTime,
+		})
+	}
+
+	// Most recently scheduled first.
+	sort.Slice(history.Jobs, func(i, j int) bool {
+		return isNewerJob(history.Jobs[i], history.Jobs[j])
+	})
+
+	return history, nil
+}
+
+// jobState returns the state of a job spawned by a cron job.
+func jobState(job *batchv1.Job) states.State {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return states.Completed
+		case batchv1.JobFailed:
+			return states.Failed
+		}
+	}
+	if job.Status.StartTime == nil {
+		return states.Pending
+	}
+	return states.Running
+}
+
+// isNewerJob returns true if job a was scheduled after job b. Jobs that have
+// not started yet were scheduled after the ones that have.
+func isNewerJob(a JobRecord, b JobRecord) bool {
+	if a.StartTime == nil {
+		return b.StartTime != nil
+	}
+	if b.StartTime == nil {
+		return false
+	}
+	return b.StartTime.Before(a.StartTime)
+}
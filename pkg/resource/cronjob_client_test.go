@@ -0,0 +1,101 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+func TestIsNewerJob(t *testing.T) {
+	now := metav1.Now()
+	earlier := metav1.NewTime(now.Add(-time.Minute))
+	jobs := []JobRecord{
+		{Name: "earlier", StartTime: &earlier},
+		{Name: "unstarted"},
+		{Name: "now", StartTime: &now},
+	}
+	sort.Slice(jobs, func(i, j int) bool {
+		return isNewerJob(jobs[i], jobs[j])
+	})
+
+	var names []string
+	for _, job := range jobs {
+		names = append(names, job.Name)
+	}
+	assert.Equal(t, []string{"unstarted", "now", "earlier"}, names)
+}
+
+func TestJobState(t *testing.T) {
+	now := metav1.Now()
+	tests := map[string]struct {
+		status   batchv1.JobStatus
+		expected states.State
+	}{
+		"not started": {
+			expected: states.Pending,
+		},
+		"started": {
+			status:   batchv1.JobStatus{StartTime: &now},
+			expected: states.Running,
+		},
+		"complete": {
+			status: batchv1.JobStatus{StartTime: &now, Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			}},
+			expected: states.Completed,
+		},
+		"failed": {
+			status: batchv1.JobStatus{StartTime: &now, Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue},
+			}},
+			expected: states.Failed,
+		},
+	}
+	for name, tc := range tests {
+		assert.Equal(t, tc.expected, jobState(&batchv1.Job{Status: tc.status}), name)
+	}
+}
+
+func TestGetJobHistoryWithoutJobTemplateLabels(t *testing.T) {
+	c := &cronJobClient{unstructuredClient: &unstructuredClient{}}
+	cronJob := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "batch/v1beta1",
+		"kind":       "CronJob",
+		"metadata":   map[string]interface{}{"name": "cronjob1", "namespace": "namespace1"},
+		"spec": map[string]interface{}{
+			"schedule":    "*/1 * * * *",
+			"jobTemplate": map[string]interface{}{},
+		},
+	}}
+
+	// The clientset is nil, so listing jobs would panic.
+	history, err := c.GetJobHistory(cronJob)
+	assert.NoError(t, err)
+	assert.Empty(t, history.Jobs)
+}
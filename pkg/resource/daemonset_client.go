@@ -0,0 +1,135 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// daemonSetGroupVersions are the group versions serving daemon sets, in
+// order of preference.
+var daemonSetGroupVersions = []schema.GroupVersion{
+	{Group: "apps", Version: "v1"},
+	{Group: "apps", Version: "v1beta2"},
+	{Group: "extensions", Version: "v1beta1"},
+}
+
+type daemonSetClient struct {
+	*unstructuredClient
+	pods *podLister
+}
+
+// NewDaemonSetClient returns a new daemon set client for the most preferred
+// API version served by the cluster. Daemon set templates of another API
+// version are converted to it. An error is returned if the API version cannot
+// be discovered.
+func NewDaemonSetClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string) (Client, error) {
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	groupVersion, err := preferredGroupVersion(clientSet.Discovery(), "daemonsets", daemonSetGroupVersions)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newUnstructuredClient(globalTemplateValues, config, UnstructuredResource{
+		GroupVersionResource: groupVersion.WithResource("daemonsets"),
+		Kind:                 "DaemonSet",
+	}, templateFileName)
+	if err != nil {
+		return nil, err
+	}
+	c.apiVersion = groupVersion
+	return &daemonSetClient{unstructuredClient: c, pods: newPodLister(clientSet)}, nil
+}
+
+func (c *daemonSetClient) WithContext(ctx context.Context) Client {
+	return &daemonSetClient{unstructuredClient: c.withContext(ctx), pods: c.pods.withContext(ctx)}
+}
+
+func (c *daemonSetClient) withInformers(informers *informer.Cache) Client {
+	return &daemonSetClient{unstructuredClient: c.unstructuredClient, pods: c.pods.withInformers(informers)}
+}
+
+func (c *daemonSetClient) IsFailed(namespace string, name string) bool {
+	return isFailed(c, namespace, name)
+}
+
+// daemonSet returns the supplied daemon set, of any API version, as an
+// apps/v1beta2 daemon set.
+func daemonSet(obj runtime.Object) *appsv1beta2.DaemonSet {
+	daemonSet := &appsv1beta2.DaemonSet{}
+	if err := fromUnstructured(obj, daemonSet); err != nil {
+		panic(fmt.Sprintf("object was not a daemon set: %v", err))
+	}
+	return daemonSet
+}
+
+func (c *daemonSetClient) isFailed(daemonSet *appsv1beta2.DaemonSet) bool {
+	// A daemon set has no failure condition of its own. Instead we inspect
+	// whether the pods controlled by the daemon set are crash looping.
+	pods, err := c.pods.list(daemonSet.Namespace, daemonSet.Spec.Selector, daemonSet.UID)
+	if err != nil {
+		return false
+	}
+
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// isPending returns true if the daemon set pods are not yet scheduled,
+// updated and ready on all eligible nodes.
+func (c *daemonSetClient) isPending(daemonSet *appsv1beta2.DaemonSet) bool {
+	// The daemon set controller has not observed the latest spec yet.
+	if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+		return true
+	}
+	desired := daemonSet.Status.DesiredNumberScheduled
+	return daemonSet.Status.NumberReady < desired ||
+		daemonSet.Status.UpdatedNumberScheduled < desired
+}
+
+func (c *daemonSetClient) GetStatusState(obj runtime.Object) states.State {
+	daemonSet := daemonSet(obj)
+	if c.isFailed(daemonSet) {
+		return states.Failed
+	}
+	if c.isPending(daemonSet) {
+		return states.Pending
+	}
+	// Completed doesn't make sense for this type.
+	return states.Running
+}
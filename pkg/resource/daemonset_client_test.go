@@ -0,0 +1,179 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// daemonSetPodServer lists the supplied pods.
+func daemonSetPodServer(t *testing.T, pods *[]corev1.Pod) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/namespace1/pods", r.URL.Path)
+		assert.Equal(t, "app=ds1", r.URL.Query().Get("labelSelector"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&corev1.PodList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"},
+			Items:    *pods,
+		})
+	}))
+}
+
+func TestDaemonSetIsPending(t *testing.T) {
+	rolledOut := appsv1beta2.DaemonSetStatus{
+		ObservedGeneration:     1,
+		DesiredNumberScheduled: 3,
+		NumberReady:            3,
+		UpdatedNumberScheduled: 3,
+	}
+	tests := map[string]struct {
+		status   func(status *appsv1beta2.DaemonSetStatus)
+		expected bool
+	}{
+		"rolled out": {
+			expected: false,
+		},
+		"generation not observed": {
+			status:   func(status *appsv1beta2.DaemonSetStatus) { status.ObservedGeneration = 0 },
+			expected: true,
+		},
+		"pods not ready": {
+			status:   func(status *appsv1beta2.DaemonSetStatus) { status.NumberReady = 2 },
+			expected: true,
+		},
+		"rolling update in progress": {
+			status:   func(status *appsv1beta2.DaemonSetStatus) { status.UpdatedNumberScheduled = 1 },
+			expected: true,
+		},
+		"no eligible nodes": {
+			status: func(status *appsv1beta2.DaemonSetStatus) {
+				status.DesiredNumberScheduled = 0
+				status.NumberReady = 0
+				status.UpdatedNumberScheduled = 0
+			},
+			expected: false,
+		},
+	}
+
+	c := &daemonSetClient{}
+	for name, tc := range tests {
+		daemonSet := &appsv1beta2.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 1},
+			Status:     rolledOut,
+		}
+		if tc.status != nil {
+			tc.status(&daemonSet.Status)
+		}
+		assert.Equal(t, tc.expected, c.isPending(daemonSet), name)
+	}
+}
+
+func TestDaemonSetIsFailed(t *testing.T) {
+	var pods []corev1.Pod
+	server := daemonSetPodServer(t, &pods)
+	defer server.Close()
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	c := &daemonSetClient{pods: newPodLister(clientSet)}
+
+	controller := true
+	pod := func(ownerUID string, state corev1.ContainerState) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "ds1-abcde",
+				Namespace:       "namespace1",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "ds1", UID: types.UID(ownerUID), Controller: &controller}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{containerStatus(state)}},
+		}
+	}
+	crashLooping := corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}
+
+	tests := map[string]struct {
+		pods     []corev1.Pod
+		expected bool
+	}{
+		"no pods": {
+			expected: false,
+		},
+		"pod running": {
+			pods:     []corev1.Pod{pod("3982", corev1.ContainerState{Running: &corev1.ContainerStateRunning{}})},
+			expected: false,
+		},
+		"pod crash looping": {
+			pods:     []corev1.Pod{pod("3982", crashLooping)},
+			expected: true,
+		},
+		"pod of another daemon set crash looping": {
+			pods:     []corev1.Pod{pod("4711", crashLooping)},
+			expected: false,
+		},
+	}
+	for name, tc := range tests {
+		pods = tc.pods
+		daemonSet := &appsv1beta2.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ds1", Namespace: "namespace1", UID: "3982"},
+			Spec:       appsv1beta2.DaemonSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "ds1"}}},
+		}
+		assert.Equal(t, tc.expected, c.isFailed(daemonSet), name)
+	}
+}
+
+func TestDaemonSetStatusExtensions(t *testing.T) {
+	var pods []corev1.Pod
+	server := daemonSetPodServer(t, &pods)
+	defer server.Close()
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	c := &daemonSetClient{pods: newPodLister(clientSet)}
+
+	// Daemon sets of an earlier API version are evaluated like
+	// apps/v1beta2 ones.
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "extensions/v1beta1",
+		"kind":       "DaemonSet",
+		"metadata":   map[string]interface{}{"name": "ds1", "namespace": "namespace1", "generation": int64(1)},
+		"spec": map[string]interface{}{
+			"selector":           map[string]interface{}{"matchLabels": map[string]interface{}{"app": "ds1"}},
+			"templateGeneration": int64(1),
+		},
+		"status": map[string]interface{}{
+			"observedGeneration":     int64(1),
+			"desiredNumberScheduled": int64(2),
+			"numberReady":            int64(1),
+			"updatedNumberScheduled": int64(2),
+		},
+	}}
+	assert.Equal(t, states.Pending, c.GetStatusState(obj))
+}
@@ -19,205 +19,266 @@
 package resource
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"net/http"
+	"io"
+	"path"
+	"strings"
 
 	"github.com/golang/glog"
-	"k8s.io/api/extensions/v1beta1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 
-	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
+// deploymentGroupVersions are the group versions serving deployments, in
+// order of preference.
+var deploymentGroupVersions = []schema.GroupVersion{
+	{Group: "apps", Version: "v1"},
+	{Group: "apps", Version: "v1beta2"},
+	extensionsv1beta1.SchemeGroupVersion,
+}
+
 type deploymentClient struct {
-	globalTemplateValues GlobalTemplateValues
-	k8sClientset         *kubernetes.Clientset
-	restClient           rest.Interface
-	resourcePluralForm   string
-	templateFileName     string
+	baseClient
+	pods *podLister
 }
 
-// NewDeploymentClient returns a new generic resource client.
+// NewDeploymentClient returns a new deployment client for the most preferred
+// API version served by the cluster, or for extensions/v1beta1 if it cannot
+// be discovered. Deployment templates of another API version are converted
+// to it. Deployments are returned as *extensionsv1beta1.Deployment whatever
+// their API version.
 func NewDeploymentClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, templateFileName string) Client {
-	return &deploymentClient{
-		globalTemplateValues: globalTemplateValues,
-		k8sClientset:         clientSet,
-		restClient:           clientSet.ExtensionsV1beta1().RESTClient(),
-		resourcePluralForm:   "deployments",
-		templateFileName:     templateFileName,
+	groupVersion, err := preferredGroupVersion(clientSet.Discovery(), "deployments", deploymentGroupVersions)
+	if err != nil {
+		glog.Errorf("%v, using %s", err, extensionsv1beta1.SchemeGroupVersion)
+		groupVersion = extensionsv1beta1.SchemeGroupVersion
 	}
+	restClient, err := restClientFor(clientSet, groupVersion, deploymentContentConfig())
+	if err != nil {
+		glog.Errorf("%v, using %s", err, extensionsv1beta1.SchemeGroupVersion)
+		groupVersion = extensionsv1beta1.SchemeGroupVersion
+		restClient = clientSet.ExtensionsV1beta1().RESTClient()
+	}
+	return newDeploymentClient(globalTemplateValues, clientSet, restClient, groupVersion, templateFileName)
 }
 
-func (c *deploymentClient) Reify(templateValues interface{}) ([]byte, error) {
-	result, err := reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
+// NewDeploymentClientForConfig is like NewDeploymentClient, but returns an
+// error if the API version cannot be discovered.
+func NewDeploymentClientForConfig(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string) (Client, error) {
+	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
-	return result, nil
-}
-
-func (c *deploymentClient) Create(namespace string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
+	groupVersion, err := preferredGroupVersion(clientSet.Discovery(), "deployments", deploymentGroupVersions)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	request := c.restClient.Post().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
+	configCopy := *config
+	configCopy.ContentConfig = deploymentContentConfig()
+	configCopy.GroupVersion = &groupVersion
+	configCopy.APIPath = "/apis"
+	restClient, err := rest.RESTClientFor(&configCopy)
 	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
+		return nil, err
 	}
-	return nil
-}
-
-func (c *deploymentClient) Delete(namespace, name string) error {
-	// For deployments the propagation policy in delete options must be set
-	// to Foreground to delete the pods along with the replica sets.
-	// See https://kubernetes.io/docs/concepts/workloads/controllers/garbage-collection/#additional-note-on-deployments.
-	deletePolicy := metav1.DeletePropagationForeground
-
-	request := c.restClient.Delete().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(&metav1.DeleteOptions{
-			PropagationPolicy: &deletePolicy,
-		})
-
-	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
-
-	return request.Do().Error()
+	return newDeploymentClient(globalTemplateValues, clientSet, restClient, groupVersion, templateFileName), nil
 }
 
-func (c *deploymentClient) Update(namespace string, name string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
+func newDeploymentClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, restClient rest.Interface, groupVersion schema.GroupVersion, templateFileName string) *deploymentClient {
+	return &deploymentClient{
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           restClient,
+			resourcePluralForm:   "deployments",
+			templateFileName:     templateFileName,
+			newObject:            newDeployment,
+			newList:              newDeploymentList,
+			// Delete the replica sets and their pods along with the
+			// deployment.
+			deletePropagation: metav1.DeletePropagationForeground,
+			apiVersion:        groupVersion,
+		},
+		pods: newPodLister(clientSet),
 	}
+}
 
-	request := c.restClient.Put().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
+func newDeployment() runtime.Object {
+	return &extensionsv1beta1.Deployment{}
+}
 
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
+func newDeploymentList() runtime.Object {
+	return &extensionsv1beta1.DeploymentList{}
+}
 
-	if err != nil {
-		return err
+// deploymentContentConfig returns the content config of the clients of
+// deployments of any API version, which decodes them as extensions/v1beta1
+// deployments. Later API versions keep their fields, apart from the
+// deprecated rollbackTo.
+func deploymentContentConfig() rest.ContentConfig {
+	var jsonInfo runtime.SerializerInfo
+	for _, info := range scheme.Codecs.SupportedMediaTypes() {
+		if info.MediaType == runtime.ContentTypeJSON {
+			jsonInfo = info
+			break
+		}
 	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
+	jsonInfo.Serializer = typedCodec{newObject: newDeployment, newList: newDeploymentList}
+	jsonInfo.PrettySerializer = nil
+	return rest.ContentConfig{
+		AcceptContentTypes:   runtime.ContentTypeJSON,
+		ContentType:          runtime.ContentTypeJSON,
+		NegotiatedSerializer: serializer.NegotiatedSerializerWrapper(jsonInfo),
 	}
-	return nil
 }
-func (c *deploymentClient) Patch(namespace string, name string, data []byte) error {
 
-	request := c.restClient.Patch(types.JSONPatchType).
-		Resource(c.resourcePluralForm).
-		Namespace(namespace).
-		Name(name).
-		Body(data)
-
-	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
-
-	return request.Do().Error()
+// typedCodec decodes JSON into the supplied object, or if none is supplied,
+// e.g. for watch events, into the object returned by newObject or newList.
+// Statuses are decoded into *metav1.Status.
+type typedCodec struct {
+	newObject func() runtime.Object
+	newList   func() runtime.Object
 }
 
-func (c *deploymentClient) Get(namespace, name string) (result runtime.Object, err error) {
-	result = &v1beta1.Deployment{}
-	opts := metav1.GetOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(result)
-
-	return result, err
+func (c typedCodec) Decode(data []byte, gvk *schema.GroupVersionKind, into runtime.Object) (runtime.Object, *schema.GroupVersionKind, error) {
+	var typeMeta metav1.TypeMeta
+	if err := json.Unmarshal(data, &typeMeta); err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case typeMeta.Kind == "Status":
+		into = &metav1.Status{}
+	case into != nil:
+	case strings.HasSuffix(typeMeta.Kind, "List"):
+		into = c.newList()
+	default:
+		into = c.newObject()
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return nil, nil, err
+	}
+	actual := typeMeta.GroupVersionKind()
+	return into, &actual, nil
 }
 
-func (c *deploymentClient) List(namespace string, labels map[string]string) (result []metav1.Object, err error) {
-	list := &v1beta1.DeploymentList{}
-	opts := metav1.ListOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(list)
+func (c typedCodec) Encode(obj runtime.Object, w io.Writer) error {
+	return json.NewEncoder(w).Encode(obj)
+}
 
-	if err != nil {
-		return result, err
+// restClientFor returns a client for the supplied group version, whose
+// objects are decoded with the supplied content config. It shares the host,
+// transport and rate limiter of the supplied client set, which has no client
+// for group versions unknown to this version of client-go, e.g. apps/v1.
+func restClientFor(clientSet *kubernetes.Clientset, groupVersion schema.GroupVersion, contentConfig rest.ContentConfig) (rest.Interface, error) {
+	coreClient, ok := clientSet.CoreV1().RESTClient().(*rest.RESTClient)
+	if !ok {
+		return nil, fmt.Errorf("cannot create a client for %s", groupVersion)
 	}
+	contentConfig.GroupVersion = &groupVersion
+	versionedAPIPath := path.Join("/apis", groupVersion.Group, groupVersion.Version)
+	return rest.NewRESTClient(coreClient.Get().AbsPath().URL(), versionedAPIPath, contentConfig, 0, 0, coreClient.GetRateLimiter(), coreClient.Client)
+}
 
-	for _, item := range list.Items {
-		// We need a copy of the item here because item has function scope whereas the copy below has a local scope.
-		// Ex: When we iterate through items, the result list will only contain multiple copies of the last item in the list.
-		depCopy := item
-		result = append(result, &depCopy)
-	}
+func (c *deploymentClient) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	clientCopy.pods = c.pods.withContext(ctx)
+	return &clientCopy
+}
 
-	return
+func (c *deploymentClient) withInformers(informers *informer.Cache) Client {
+	clientCopy := *c
+	clientCopy.pods = c.pods.withInformers(informers)
+	return &clientCopy
 }
 
 func (c *deploymentClient) IsEphemeral() bool {
 	return false
 }
 
-func (c *deploymentClient) Plural() string {
-	return c.resourcePluralForm
-}
-
 func (c *deploymentClient) IsFailed(namespace string, name string) bool {
-	obj, err := c.Get(namespace, name)
-	if err != nil {
-		return false
-	}
-	return c.isFailed(obj)
+	return isFailed(c, namespace, name)
 }
 
-func (c *deploymentClient) isFailed(obj runtime.Object) bool {
-	dep, ok := obj.(*v1beta1.Deployment)
+// deployment returns the supplied deployment, of any API version, as an
+// apps/v1beta2 deployment.
+func (c *deploymentClient) deployment(obj runtime.Object) *appsv1beta2.Deployment {
+	dep, ok := obj.(*extensionsv1beta1.Deployment)
 	if !ok {
-		panic("object was not a *v1beta1.Deployment")
+		panic("object was not a *extensionsv1beta1.Deployment")
 	}
-	conditions := dep.Status.Conditions
-	if len(conditions) == 0 {
-		return false
+	return fromExtensionsDeployment(dep)
+}
+
+// fromExtensionsDeployment converts the fields of the supplied
+// extensions/v1beta1 deployment that its state depends on.
+func fromExtensionsDeployment(dep *extensionsv1beta1.Deployment) *appsv1beta2.Deployment {
+	result := &appsv1beta2.Deployment{
+		ObjectMeta: dep.ObjectMeta,
+		Spec: appsv1beta2.DeploymentSpec{
+			Replicas: dep.Spec.Replicas,
+			Selector: dep.Spec.Selector,
+		},
+		Status: appsv1beta2.DeploymentStatus{
+			ObservedGeneration:  dep.Status.ObservedGeneration,
+			Replicas:            dep.Status.Replicas,
+			UpdatedReplicas:     dep.Status.UpdatedReplicas,
+			ReadyReplicas:       dep.Status.ReadyReplicas,
+			AvailableReplicas:   dep.Status.AvailableReplicas,
+			UnavailableReplicas: dep.Status.UnavailableReplicas,
+		},
 	}
-	latestCondition := conditions[0]
-	for i := range conditions {
-		time1 := &latestCondition.LastUpdateTime
-		time2 := &conditions[i].LastUpdateTime
-		if time1.Before(time2) {
-			latestCondition = conditions[i]
-		}
+	for _, condition := range dep.Status.Conditions {
+		result.Status.Conditions = append(result.Status.Conditions, appsv1beta2.DeploymentCondition{
+			Type:    appsv1beta2.DeploymentConditionType(condition.Type),
+			Status:  condition.Status,
+			Reason:  condition.Reason,
+			Message: condition.Message,
+		})
 	}
+	return result
+}
+
+func (c *deploymentClient) GetStatusState(obj runtime.Object) states.State {
+	state, _ := c.deploymentStatus(obj)
+	return state
+}
 
-	if latestCondition.Type == v1beta1.DeploymentReplicaFailure {
-		return true
+func (c *deploymentClient) GetStatusReason(obj runtime.Object) string {
+	_, reason := c.deploymentStatus(obj)
+	return reason
+}
+
+// deploymentStatus returns the state of the supplied deployment and, if it
+// is pending or failed, the reason why. Completed doesn't make sense for
+// this type.
+func (c *deploymentClient) deploymentStatus(obj runtime.Object) (states.State, string) {
+	dep := c.deployment(obj)
+
+	available := false
+	for _, condition := range dep.Status.Conditions {
+		switch {
+		case condition.Type == appsv1beta2.DeploymentProgressing && condition.Status == corev1.ConditionFalse:
+			// E.g. ProgressDeadlineExceeded.
+			return states.Failed, joinReason(condition.Reason, condition.Message)
+		case condition.Type == appsv1beta2.DeploymentReplicaFailure && condition.Status == corev1.ConditionTrue:
+			return states.Failed, joinReason(condition.Reason, condition.Message)
+		case condition.Type == appsv1beta2.DeploymentAvailable && condition.Status == corev1.ConditionTrue:
+			available = true
+		}
 	}
 
 	// If the deployment is not in a failed state we inspect whether the
@@ -225,32 +286,34 @@ func (c *deploymentClient) isFailed(obj runtime.Object) bool {
 	// This is required because the definition of pod failure in kubernetes is
 	// strict. The pod is considered failed iff all containers in the pod have
 	// terminated, and at least one container has terminated in a failure (exited
-	// with a non-zero exit code or was stopped by the system). If the pod gets to
-	// a failed state the controlling object (e.g., deployment),
-	// enters a failed state (i.e., DeploymentReplicaFailure state in case of
-	// deployment) as well.
-	podClient := NewPodClient(GlobalTemplateValues{}, c.k8sClientset, "")
-
-	// List all the pods with the same labels as the deployment and check if
-	// they have failed.
-	podList, err := podClient.List(dep.ObjectMeta.Namespace, dep.ObjectMeta.Labels)
-	if err != nil {
-		return false
-	}
-
-	for _, pod := range podList {
-		if podClient.IsFailed(pod.GetNamespace(), pod.GetName()) {
-			return true
+	// with a non-zero exit code or was stopped by the system). Until the
+	// progress deadline is exceeded, the deployment conditions don't reflect
+	// crash looping pods.
+	//
+	// The pods are controlled by the replica sets of the deployment, so they
+	// are selected by the deployment selector alone.
+	pods, err := c.pods.list(dep.Namespace, dep.Spec.Selector, "")
+	if err == nil {
+		for _, pod := range pods {
+			if state, reason := podState(pod); state == states.Failed {
+				return states.Failed, fmt.Sprintf(`pod "%s" failed: %s`, pod.Name, reason)
+			}
 		}
 	}
 
-	return false
-}
-
-func (c *deploymentClient) GetStatusState(obj runtime.Object) states.State {
-	if c.isFailed(obj) {
-		return states.Failed
+	replicas := int32(1)
+	if dep.Spec.Replicas != nil {
+		replicas = *dep.Spec.Replicas
+	}
+	switch {
+	case dep.Status.ObservedGeneration < dep.Generation:
+		return states.Pending, "waiting for the rollout to be observed"
+	case dep.Status.UpdatedReplicas < replicas:
+		return states.Pending, fmt.Sprintf("%d of %d replicas updated", dep.Status.UpdatedReplicas, replicas)
+	case dep.Status.UnavailableReplicas > 0:
+		return states.Pending, fmt.Sprintf("%d of %d replicas unavailable", dep.Status.UnavailableReplicas, replicas)
+	case !available:
+		return states.Pending, "deployment is not available"
 	}
-	// TODO(CD): Detect Pending state. Completed doesn't make sense for this type.
-	return states.Running
+	return states.Running, ""
 }
@@ -0,0 +1,226 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+func TestDeploymentStatusExtensions(t *testing.T) {
+	c := &deploymentClient{pods: newPodLister(nil)}
+	replicas := int32(2)
+	tests := map[string]struct {
+		status   extensionsv1beta1.DeploymentStatus
+		expected states.State
+	}{
+		"available": {
+			status: extensionsv1beta1.DeploymentStatus{
+				UpdatedReplicas: 2,
+				Conditions: []extensionsv1beta1.DeploymentCondition{
+					{Type: extensionsv1beta1.DeploymentAvailable, Status: corev1.ConditionTrue},
+				},
+			},
+			expected: states.Running,
+		},
+		"rolling out": {
+			status: extensionsv1beta1.DeploymentStatus{
+				UpdatedReplicas: 1,
+				Conditions: []extensionsv1beta1.DeploymentCondition{
+					{Type: extensionsv1beta1.DeploymentAvailable, Status: corev1.ConditionTrue},
+				},
+			},
+			expected: states.Pending,
+		},
+		"progress deadline exceeded": {
+			status: extensionsv1beta1.DeploymentStatus{
+				Conditions: []extensionsv1beta1.DeploymentCondition{
+					{Type: extensionsv1beta1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+				},
+			},
+			expected: states.Failed,
+		},
+	}
+	for name, tc := range tests {
+		// Without a selector, no pods are listed.
+		dep := &extensionsv1beta1.Deployment{
+			TypeMeta: metav1.TypeMeta{APIVersion: "extensions/v1beta1", Kind: "Deployment"},
+			Spec:     extensionsv1beta1.DeploymentSpec{Replicas: &replicas},
+			Status:   tc.status,
+		}
+		assert.Equal(t, tc.expected, c.GetStatusState(dep), name)
+	}
+}
+
+func TestPodListerWithContext(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"apiVersion":"v1","kind":"PodList","items":[{"metadata":{"name":"pod1"}}]}`)
+	}))
+	defer server.Close()
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app1"}}
+	lister := newPodLister(clientSet)
+
+	// Unbound listers always list.
+	for i := 0; i < 2; i++ {
+		pods, err := lister.list("namespace1", selector, "")
+		require.NoError(t, err)
+		assert.Len(t, pods, 1)
+	}
+	assert.Equal(t, 2, requests)
+
+	// Listers bound to a pass list each selector once.
+	pass := lister.withContext(context.Background())
+	for i := 0; i < 2; i++ {
+		pods, err := pass.list("namespace1", selector, "")
+		require.NoError(t, err)
+		assert.Len(t, pods, 1)
+	}
+	assert.Equal(t, 3, requests)
+
+	_, err = pass.list("namespace1", &metav1.LabelSelector{MatchLabels: map[string]string{"app": "app2"}}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, requests)
+}
+
+const deploymentTemplate = `apiVersion: extensions/v1beta1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+spec:
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      containers:
+      - name: c1
+        image: busybox
+`
+
+// deploymentServer serves apps/v1 deployments, and records the bodies of
+// the deployments created.
+func deploymentServer(t *testing.T, created *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/apis/apps/v1":
+			fmt.Fprint(w, `{"kind":"APIResourceList","groupVersion":"apps/v1","resources":[{"name":"deployments","namespaced":true,"kind":"Deployment"}]}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/apis/apps/v1/namespaces/namespace1/deployments":
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			*created = append(*created, string(body))
+			w.WriteHeader(http.StatusCreated)
+			w.Write(body)
+		case r.URL.Path == "/apis/apps/v1/namespaces/namespace1/deployments/cr1":
+			fmt.Fprint(w, `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"cr1","namespace":"namespace1"},"status":{"readyReplicas":1}}`)
+		case r.URL.Path == "/apis/apps/v1/namespaces/namespace1/deployments":
+			fmt.Fprint(w, `{"apiVersion":"apps/v1","kind":"DeploymentList","metadata":{},"items":[{"metadata":{"name":"cr1","namespace":"namespace1"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"apiVersion":"v1","kind":"Status","status":"Failure","reason":"NotFound","code":404}`)
+		}
+	}))
+}
+
+func TestDeploymentClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deployment")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	templateFileName := filepath.Join(dir, "deployment.yaml")
+	require.NoError(t, ioutil.WriteFile(templateFileName, []byte(deploymentTemplate), 0644))
+
+	var created []string
+	server := deploymentServer(t, &created)
+	defer server.Close()
+	config := &rest.Config{Host: server.URL}
+	clientSet, err := kubernetes.NewForConfig(config)
+	require.NoError(t, err)
+	forConfig, err := NewDeploymentClientForConfig(GlobalTemplateValues{}, config, templateFileName)
+	require.NoError(t, err)
+
+	clients := map[string]Client{
+		"client set": NewDeploymentClient(GlobalTemplateValues{}, clientSet, templateFileName),
+		"config":     forConfig,
+	}
+	cr := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cr1", UID: "3982"},
+	}
+	for name, c := range clients {
+		created = nil
+		gvr := c.(CacheableClient).GroupVersionResource()
+		assert.Equal(t, "apps/v1, Resource=deployments", gvr.String(), name)
+
+		// The template is converted to apps/v1, which requires a selector.
+		obj, err := c.CreateObject("namespace1", cr)
+		require.NoError(t, err, name)
+		assert.IsType(t, &extensionsv1beta1.Deployment{}, obj, name)
+		require.Len(t, created, 1, name)
+		body := &unstructured.Unstructured{}
+		require.NoError(t, body.UnmarshalJSON([]byte(created[0])), name)
+		assert.Equal(t, "apps/v1", body.GetAPIVersion(), name)
+		assert.Equal(t, map[string]interface{}{"matchLabels": map[string]interface{}{"app": "cr1"}}, body.Object["spec"].(map[string]interface{})["selector"], name)
+		// The checksum is that of the template, so that the reconciler does
+		// not take the conversion for a change of the template.
+		reified, err := c.Reify(cr)
+		require.NoError(t, err, name)
+		assert.Equal(t, TemplateChecksum(reified), body.GetAnnotations()[TemplateChecksumAnnotation], name)
+		checksum, err := Unwrap(c).(TemplateChecksumClient).TemplateChecksum(cr)
+		require.NoError(t, err, name)
+		assert.Equal(t, checksum, body.GetAnnotations()[TemplateChecksumAnnotation], name)
+
+		// Deployments are returned as extensions/v1beta1 deployments.
+		obj, err = c.Get("namespace1", "cr1")
+		require.NoError(t, err, name)
+		dep, ok := obj.(*extensionsv1beta1.Deployment)
+		require.True(t, ok, name)
+		assert.Equal(t, int32(1), dep.Status.ReadyReplicas, name)
+
+		list, err := c.List("namespace1", nil)
+		require.NoError(t, err, name)
+		require.Len(t, list, 1, name)
+		assert.IsType(t, &extensionsv1beta1.Deployment{}, list[0], name)
+
+		_, err = c.Get("namespace1", "cr2")
+		assert.True(t, IsNotFound(err), name)
+	}
+}
@@ -0,0 +1,174 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds of API errors, for use with errors.Is on the errors returned by
+// resource clients. With Go releases before 1.13, use IsNotFound,
+// IsAlreadyExists, IsConflict and IsInvalid instead.
+var (
+	// ErrNotFound is matched by API errors for missing objects.
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists is matched by API errors for objects that are
+	// created twice.
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrConflict is matched by API errors for updates of out of date
+	// objects, and by server-side apply conflicts.
+	ErrConflict = errors.New("conflict")
+	// ErrInvalid is matched by API errors for objects rejected by API
+	// server validation.
+	ErrInvalid = errors.New("invalid")
+)
+
+// IsNotFound returns true if the supplied error is an API error for a missing
+// object.
+func IsNotFound(err error) bool {
+	return isKind(err, ErrNotFound)
+}
+
+// IsAlreadyExists returns true if the supplied error is an API error for an
+// object that is created twice.
+func IsAlreadyExists(err error) bool {
+	return isKind(err, ErrAlreadyExists)
+}
+
+// IsConflict returns true if the supplied error is an API error for an update
+// of an out of date object, or a server-side apply conflict.
+func IsConflict(err error) bool {
+	return isKind(err, ErrConflict)
+}
+
+// IsInvalid returns true if the supplied error is an API error for an object
+// rejected by API server validation.
+func IsInvalid(err error) bool {
+	return isKind(err, ErrInvalid)
+}
+
+// isKind returns true if the supplied error, wrapped in an APIError or not,
+// is of the supplied kind.
+func isKind(err error, kind error) bool {
+	if err == nil {
+		return false
+	}
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		apiErr = &APIError{Err: err}
+	}
+	return apiErr.Is(kind)
+}
+
+// TemplateError reports that a template could not be rendered into an
+// object.
+type TemplateError struct {
+	TemplateFileName string
+	Err              error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf(`rendering template "%s": %v`, e.TemplateFileName, e.Err)
+}
+
+// Unwrap returns the template or conversion error.
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// APIError reports a failed API server request. It wraps the underlying
+// error, usually an *apierrors.StatusError, and exposes its status so that
+// the apierrors predicates, e.g. apierrors.IsNotFound, keep working.
+type APIError struct {
+	// Verb is the client operation, e.g. "create" or "delete".
+	Verb      string
+	Plural    string
+	Namespace string
+	Name      string
+	Err       error
+}
+
+// wrapAPIError returns the supplied request error wrapped in an APIError, or
+// nil if there is no error.
+func wrapAPIError(verb string, plural string, namespace string, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &APIError{
+		Verb:      verb,
+		Plural:    plural,
+		Namespace: namespace,
+		Name:      name,
+		Err:       err,
+	}
+}
+
+func (e *APIError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf(`%s "%s" in namespace "%s": %v`, e.Verb, e.Plural, e.Namespace, e.Err)
+	}
+	return fmt.Sprintf(`%s "%s" "%s" in namespace "%s": %v`, e.Verb, e.Plural, e.Name, e.Namespace, e.Err)
+}
+
+// Unwrap returns the underlying request error.
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the supplied kind, e.g. ErrNotFound.
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return apierrors.IsNotFound(e.Err)
+	case ErrAlreadyExists:
+		return apierrors.IsAlreadyExists(e.Err)
+	case ErrConflict:
+		return apierrors.IsConflict(e.Err)
+	case ErrInvalid:
+		return apierrors.IsInvalid(e.Err)
+	}
+	return false
+}
+
+// Status returns the API status of the underlying error. It makes APIError
+// an apierrors.APIStatus.
+func (e *APIError) Status() metav1.Status {
+	if status, ok := e.Err.(apierrors.APIStatus); ok {
+		return status.Status()
+	}
+	return metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonUnknown,
+		Message: e.Err.Error(),
+	}
+}
+
+// UnexpectedStatusError reports a successful API server response with a
+// status code other than 200 OK or 201 Created.
+type UnexpectedStatusError struct {
+	StatusCode int
+}
+
+func (e *UnexpectedStatusError) Error() string {
+	return fmt.Sprintf("unexpected status code (%d)", e.StatusCode)
+}
@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var podsResource = schema.GroupResource{Resource: "pods"}
+
+func TestWrapAPIError(t *testing.T) {
+	tests := map[string]struct {
+		name     string
+		err      error
+		expected string
+	}{
+		"named": {
+			name:     "pod1",
+			err:      apierrors.NewNotFound(podsResource, "pod1"),
+			expected: `get "pods" "pod1" in namespace "namespace1": pods "pod1" not found`,
+		},
+		"unnamed": {
+			err:      errors.New("connection refused"),
+			expected: `get "pods" in namespace "namespace1": connection refused`,
+		},
+	}
+	for name, tc := range tests {
+		err := wrapAPIError("get", "pods", "namespace1", tc.name, tc.err)
+		apiErr, ok := err.(*APIError)
+		require.True(t, ok, name)
+		assert.Equal(t, tc.expected, err.Error(), name)
+		assert.Equal(t, tc.err, apiErr.Unwrap(), name)
+	}
+
+	assert.NoError(t, wrapAPIError("get", "pods", "namespace1", "pod1", nil))
+}
+
+func TestAPIErrorIs(t *testing.T) {
+	kinds := []error{ErrNotFound, ErrAlreadyExists, ErrConflict, ErrInvalid}
+	tests := map[string]struct {
+		err      error
+		expected error
+		reason   metav1.StatusReason
+	}{
+		"not found": {
+			err:      apierrors.NewNotFound(podsResource, "pod1"),
+			expected: ErrNotFound,
+			reason:   metav1.StatusReasonNotFound,
+		},
+		"already exists": {
+			err:      apierrors.NewAlreadyExists(podsResource, "pod1"),
+			expected: ErrAlreadyExists,
+			reason:   metav1.StatusReasonAlreadyExists,
+		},
+		"conflict": {
+			err:      apierrors.NewConflict(podsResource, "pod1", errors.New("out of date")),
+			expected: ErrConflict,
+			reason:   metav1.StatusReasonConflict,
+		},
+		"invalid": {
+			err:      apierrors.NewInvalid(schema.GroupKind{Kind: "Pod"}, "pod1", field.ErrorList{field.Required(field.NewPath("spec"), "")}),
+			expected: ErrInvalid,
+			reason:   metav1.StatusReasonInvalid,
+		},
+		"internal": {
+			err:    apierrors.NewInternalError(errors.New("failure")),
+			reason: metav1.StatusReasonInternalError,
+		},
+		"not an API status": {
+			err:    errors.New("connection refused"),
+			reason: metav1.StatusReasonUnknown,
+		},
+	}
+	for name, tc := range tests {
+		err := wrapAPIError("get", "pods", "namespace1", "pod1", tc.err)
+		apiErr := err.(*APIError)
+		for _, kind := range kinds {
+			assert.Equal(t, kind == tc.expected, apiErr.Is(kind), "%s is %v", name, kind)
+		}
+		assert.Equal(t, tc.expected == ErrNotFound, IsNotFound(err), name)
+		assert.Equal(t, tc.expected == ErrAlreadyExists, IsAlreadyExists(err), name)
+		assert.Equal(t, tc.expected == ErrConflict, IsConflict(err), name)
+		assert.Equal(t, tc.expected == ErrInvalid, IsInvalid(err), name)
+		// The helpers work on unwrapped errors too.
+		assert.Equal(t, tc.expected == ErrNotFound, IsNotFound(tc.err), name)
+		// Wrapped errors keep the status of the underlying error.
+		assert.Equal(t, tc.reason, err.(apierrors.APIStatus).Status().Reason, name)
+	}
+
+	assert.False(t, IsNotFound(nil))
+}
+
+func TestTemplateError(t *testing.T) {
+	tests := map[string]struct {
+		err      *TemplateError
+		expected string
+	}{
+		"parse": {
+			err:      &TemplateError{TemplateFileName: "pod.yaml", Err: errors.New("unexpected EOF")},
+			expected: `rendering template "pod.yaml": unexpected EOF`,
+		},
+		"no file name": {
+			err:      &TemplateError{Err: errors.New("unexpected EOF")},
+			expected: `rendering template "": unexpected EOF`,
+		},
+	}
+	for name, tc := range tests {
+		assert.Equal(t, tc.expected, tc.err.Error(), name)
+		assert.Equal(t, tc.err.Err, tc.err.Unwrap(), name)
+		assert.False(t, IsNotFound(tc.err), name)
+	}
+}
+
+func TestUnexpectedStatusError(t *testing.T) {
+	tests := map[int]string{
+		202: "unexpected status code (202)",
+		204: "unexpected status code (204)",
+	}
+	for statusCode, expected := range tests {
+		err := wrapAPIError("create", "pods", "namespace1", "pod1", &UnexpectedStatusError{StatusCode: statusCode})
+		assert.Equal(t, `create "pods" "pod1" in namespace "namespace1": `+expected, err.Error())
+		assert.Equal(t, metav1.StatusReasonUnknown, err.(apierrors.APIStatus).Status().Reason)
+		assert.False(t, IsNotFound(err))
+	}
+}
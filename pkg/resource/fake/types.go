@@ -19,11 +19,16 @@
 package fake
 
 import (
+	"context"
 	"fmt"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
@@ -64,6 +69,11 @@ func (c *SubresourceClient) Create(namespace string, templateValues interface{})
 	return
 }
 
+// CreateObject returns the fake subresource
+func (c *SubresourceClient) CreateObject(namespace string, templateValues interface{}) (result runtime.Object, e error) {
+	return c.Get(namespace, "")
+}
+
 // Delete deletes a fake resource.Client
 func (c *SubresourceClient) Delete(namespace, name string) (e error) {
 	if c.Error != "" {
@@ -80,6 +90,11 @@ func (c *SubresourceClient) Update(namespace string, name string, templateValues
 	return
 }
 
+// UpdateObject returns the fake subresource
+func (c *SubresourceClient) UpdateObject(namespace string, name string, templateValues interface{}) (result runtime.Object, e error) {
+	return c.Get(namespace, name)
+}
+
 // Patch patches a fake resource.Client
 func (c *SubresourceClient) Patch(namespace string, name string, data []byte) (e error) {
 	if c.Error != "" {
@@ -88,15 +103,27 @@ func (c *SubresourceClient) Patch(namespace string, name string, data []byte) (e
 	return
 }
 
-// Get returns a fake runtime.Object
+// PatchObject returns the fake subresource
+func (c *SubresourceClient) PatchObject(namespace string, name string, patchType types.PatchType, data []byte) (runtime.Object, error) {
+	return c.Get(namespace, name)
+}
+
+// Apply returns the fake subresource
+func (c *SubresourceClient) Apply(namespace string, name string, templateValues interface{}, opts resource.ApplyOptions) (runtime.Object, error) {
+	return c.Get(namespace, name)
+}
+
+// Get returns the fake subresource, or a not found error if it has another
+// name
 func (c *SubresourceClient) Get(namespace, name string) (result runtime.Object, e error) {
 	if c.Error != "" {
-		e = fmt.Errorf(c.Error)
-	} else {
-		sub := c.Subresource
-		result = sub.(runtime.Object)
+		return nil, fmt.Errorf(c.Error)
 	}
-	return
+	result, ok := c.Subresource.(runtime.Object)
+	if !ok || name != "" && c.Subresource.GetName() != name {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: c.PluralValue}, name)
+	}
+	return result, nil
 }
 
 // List returns an array of fake metav1.Objects or error
@@ -109,6 +136,17 @@ func (c *SubresourceClient) List(namespace string, labels map[string]string) (re
 	return
 }
 
+// ListWithOptions lists fake resource.Client objects in a single page
+func (c *SubresourceClient) ListWithOptions(namespace string, opts metav1.ListOptions) ([]metav1.Object, string, error) {
+	result, e := c.List(namespace, map[string]string{})
+	return result, "", e
+}
+
+// WithContext returns the fake resource.Client itself
+func (c *SubresourceClient) WithContext(ctx context.Context) resource.Client {
+	return c
+}
+
 // IsFailed returns true if the resource is in a Failed state
 func (c *SubresourceClient) IsFailed(namespace string, name string) bool {
 	if c.Subresource.(*Subresource).StatusState == states.Failed {
@@ -127,7 +165,11 @@ func (c *SubresourceClient) Plural() (plural string) {
 	return c.PluralValue
 }
 
-// GetStatusState returns the current status of the subresource.
-func (c *SubresourceClient) GetStatusState(runtime.Object) states.State {
+// GetStatusState returns the current status of the supplied subresource,
+// or of the client's subresource if the supplied object is not one.
+func (c *SubresourceClient) GetStatusState(obj runtime.Object) states.State {
+	if sub, ok := obj.(*Subresource); ok {
+		return sub.StatusState
+	}
 	return c.Subresource.(*Subresource).StatusState
 }
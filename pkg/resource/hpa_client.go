@@ -19,173 +19,102 @@
 package resource
 
 import (
-	"fmt"
-	"net/http"
+	"context"
+	"encoding/json"
 
 	"github.com/golang/glog"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
 
-	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
 type hpaClient struct {
-	globalTemplateValues GlobalTemplateValues
-	restClient           rest.Interface
-	resourcePluralForm   string
-	templateFileName     string
+	baseClient
 }
 
 // NewHPAClient returns a new horizontal pod autoscaler client.
 func NewHPAClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, templateFileName string) Client {
 	return &hpaClient{
-		globalTemplateValues: globalTemplateValues,
-		restClient:           clientSet.AutoscalingV1().RESTClient(),
-		resourcePluralForm:   "horizontalpodautoscalers",
-		templateFileName:     templateFileName,
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           clientSet.AutoscalingV1().RESTClient(),
+			resourcePluralForm:   "horizontalpodautoscalers",
+			templateFileName:     templateFileName,
+			newObject:            func() runtime.Object { return &autoscalingv1.HorizontalPodAutoscaler{} },
+			newList:              func() runtime.Object { return &autoscalingv1.HorizontalPodAutoscalerList{} },
+		},
 	}
 }
 
-func (c *hpaClient) Reify(templateValues interface{}) ([]byte, error) {
-	result, err := reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
-}
-
-func (c *hpaClient) Create(namespace string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Post().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
-	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
-	}
-	return nil
+func (c *hpaClient) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	return &clientCopy
 }
 
-func (c *hpaClient) Delete(namespace, name string) error {
-	request := c.restClient.Delete().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name)
-
-	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
-
-	return request.Do().Error()
+func (c *hpaClient) IsEphemeral() bool {
+	return true
 }
 
-func (c *hpaClient) Update(namespace string, name string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Put().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
+func (c *hpaClient) IsFailed(namespace string, name string) bool {
+	obj, err := c.Get(namespace, name)
 	if err != nil {
-		return err
+		return false
 	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
-	}
-	return nil
+	return c.GetStatusState(obj) == states.Failed
 }
 
-func (c *hpaClient) Patch(namespace string, name string, data []byte) error {
-
-	request := c.restClient.Patch(types.JSONPatchType).
-		Resource(c.resourcePluralForm).
-		Namespace(namespace).
-		Name(name).
-		Body(data)
-
-	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
-
-	return request.Do().Error()
+func (c *hpaClient) GetStatusState(obj runtime.Object) states.State {
+	state, _ := c.hpaStatus(obj)
+	return state
 }
 
-func (c *hpaClient) Get(namespace, name string) (result runtime.Object, err error) {
-	result = &autoscalingv1.HorizontalPodAutoscaler{}
-	opts := metav1.GetOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(result)
-
-	return result, err
+func (c *hpaClient) GetStatusReason(obj runtime.Object) string {
+	_, reason := c.hpaStatus(obj)
+	return reason
 }
 
-func (c *hpaClient) List(namespace string, labels map[string]string) (result []metav1.Object, err error) {
-	list := &autoscalingv1.HorizontalPodAutoscalerList{}
-	opts := metav1.ListOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(list)
+// hpaConditionsAnnotation holds the conditions of a horizontal pod
+// autoscaler, which autoscaling/v1 doesn't have a field for.
+const hpaConditionsAnnotation = "autoscaling.alpha.kubernetes.io/conditions"
+
+// hpaCondition is a condition of a horizontal pod autoscaler, as stored in
+// the conditions annotation.
+type hpaCondition struct {
+	Type    string                 `json:"type"`
+	Status  corev1.ConditionStatus `json:"status"`
+	Reason  string                 `json:"reason,omitempty"`
+	Message string                 `json:"message,omitempty"`
+}
 
-	if err != nil {
-		return []metav1.Object{}, err
+// hpaStatus returns the state of the supplied horizontal pod autoscaler
+// and, if it failed, the reason why. Pending and Completed don't make sense
+// for this type.
+func (c *hpaClient) hpaStatus(obj runtime.Object) (states.State, string) {
+	hpa, ok := obj.(*autoscalingv1.HorizontalPodAutoscaler)
+	if !ok {
+		panic("object was not a *autoscalingv1.HorizontalPodAutoscaler")
 	}
 
-	for _, item := range list.Items {
-		// We need a copy of the item here because item has function scope whereas the copy below has a local scope.
-		// Ex: When we iterate through items, the result list will only contain multiple copies of the last item in the list.
-		hpaCopy := item
-		result = append(result, &hpaCopy)
+	annotation, ok := hpa.Annotations[hpaConditionsAnnotation]
+	if !ok {
+		return states.Running, ""
 	}
-
-	return
-}
-
-func (c *hpaClient) IsEphemeral() bool {
-	return true
-}
-
-func (c *hpaClient) Plural() string {
-	return c.resourcePluralForm
-}
-
-func (c *hpaClient) IsFailed(namespace string, name string) bool {
-	return false
-}
-
-func (c *hpaClient) GetStatusState(obj runtime.Object) states.State {
-	// TODO(CD): Detect Pending and Failed states. Completed doesn't make sense for this type.
-	return states.Running
+	var conditions []hpaCondition
+	if err := json.Unmarshal([]byte(annotation), &conditions); err != nil {
+		glog.Warningf("[hpa] failed to decode conditions of %s: %v", hpa.Name, err)
+		return states.Running, ""
+	}
+	for _, condition := range conditions {
+		if condition.Type != "ScalingActive" && condition.Type != "AbleToScale" {
+			continue
+		}
+		if condition.Status == corev1.ConditionFalse {
+			return states.Failed, joinReason(condition.Reason, condition.Message)
+		}
+	}
+	return states.Running, ""
 }
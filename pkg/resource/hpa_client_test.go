@@ -0,0 +1,75 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+func TestHPAStatusState(t *testing.T) {
+	tests := map[string]struct {
+		conditions string
+		expected   states.State
+		reason     string
+	}{
+		"no conditions": {
+			expected: states.Running,
+		},
+		"scaling": {
+			conditions: `[{"type":"AbleToScale","status":"True"},{"type":"ScalingActive","status":"True"}]`,
+			expected:   states.Running,
+		},
+		"no metrics": {
+			conditions: `[{"type":"AbleToScale","status":"True"},{"type":"ScalingActive","status":"False","reason":"FailedGetResourceMetric","message":"unable to get metrics"}]`,
+			expected:   states.Failed,
+			reason:     "FailedGetResourceMetric: unable to get metrics",
+		},
+		"no target": {
+			conditions: `[{"type":"AbleToScale","status":"False","reason":"FailedGetScale"}]`,
+			expected:   states.Failed,
+			reason:     "FailedGetScale",
+		},
+		"limited": {
+			conditions: `[{"type":"ScalingLimited","status":"False","reason":"DesiredWithinRange"}]`,
+			expected:   states.Running,
+		},
+		"undecodable conditions": {
+			conditions: `[{"type":`,
+			expected:   states.Running,
+		},
+	}
+
+	c := &hpaClient{}
+	for name, tc := range tests {
+		hpa := &autoscalingv1.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "hpa1", Namespace: "namespace1"},
+		}
+		if tc.conditions != "" {
+			hpa.Annotations = map[string]string{hpaConditionsAnnotation: tc.conditions}
+		}
+		assert.Equal(t, tc.expected, c.GetStatusState(hpa), name)
+		assert.Equal(t, tc.reason, c.GetStatusReason(hpa), name)
+	}
+}
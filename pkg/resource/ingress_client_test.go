@@ -0,0 +1,58 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+func TestIngressStatusState(t *testing.T) {
+	tests := map[string]struct {
+		loadBalancer corev1.LoadBalancerStatus
+		expected     states.State
+	}{
+		"not served yet": {
+			expected: states.Pending,
+		},
+		"served by IP": {
+			loadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}},
+			expected:     states.Running,
+		},
+		"served by host name": {
+			loadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{Hostname: "lb.example.com"}}},
+			expected:     states.Running,
+		},
+	}
+
+	c := &ingressClient{}
+	for name, tc := range tests {
+		ingress := &v1beta1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "ingress1", Namespace: "namespace1"},
+			Status:     v1beta1.IngressStatus{LoadBalancer: tc.loadBalancer},
+		}
+		assert.Equal(t, tc.expected, c.GetStatusState(ingress), name)
+	}
+}
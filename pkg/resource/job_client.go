@@ -19,269 +19,180 @@
 package resource
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"time"
 
-	"github.com/golang/glog"
 	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 
-	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
 type jobClient struct {
-	globalTemplateValues GlobalTemplateValues
-	restClient           rest.Interface
-	k8sClientset         *kubernetes.Clientset
-	resourcePluralForm   string
-	templateFileName     string
+	baseClient
+	pods *podLister
 }
 
-// NewJobClient returns a new generic resource client.
+// NewJobClient returns a new job client. The number of completions is
+// available through the ProgressClient interface.
 func NewJobClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, templateFileName string) Client {
-	return &jobClient{
-		globalTemplateValues: globalTemplateValues,
-		restClient:           clientSet.BatchV1().RESTClient(),
-		k8sClientset:         clientSet,
-		resourcePluralForm:   "jobs",
-		templateFileName:     templateFileName,
-	}
+	c := newJobClient(globalTemplateValues, clientSet.BatchV1().RESTClient(), templateFileName)
+	c.pods = newPodLister(clientSet)
+	return c
 }
 
-func (c *jobClient) Reify(templateValues interface{}) ([]byte, error) {
-	result, err := reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
-	if err != nil {
-		return nil, err
+// newJobClient returns a job client that sends its requests with the
+// supplied REST client, and evaluates jobs without listing their pods.
+func newJobClient(globalTemplateValues GlobalTemplateValues, restClient rest.Interface, templateFileName string) *jobClient {
+	return &jobClient{
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           restClient,
+			resourcePluralForm:   "jobs",
+			templateFileName:     templateFileName,
+			newObject:            func() runtime.Object { return &batchv1.Job{} },
+			newList:              func() runtime.Object { return &batchv1.JobList{} },
+			deletePropagation:    metav1.DeletePropagationForeground,
+		},
 	}
-	return result, nil
 }
 
-func (c *jobClient) Create(namespace string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Post().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
-	if err != nil {
-		return err
+func (c *jobClient) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	if c.pods != nil {
+		clientCopy.pods = c.pods.withContext(ctx)
 	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
-	}
-	return nil
-}
-
-func (c *jobClient) Delete(namespace, name string) error {
-	deletePolicy := metav1.DeletePropagationForeground
-	request := c.restClient.Delete().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(&metav1.DeleteOptions{
-			PropagationPolicy: &deletePolicy,
-		})
-
-	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
-
-	return request.Do().Error()
+	return &clientCopy
 }
 
-func (c *jobClient) Update(namespace string, name string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
+func (c *jobClient) withInformers(informers *informer.Cache) Client {
+	clientCopy := *c
+	if c.pods != nil {
+		clientCopy.pods = c.pods.withInformers(informers)
 	}
+	return &clientCopy
+}
 
-	request := c.restClient.Put().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
+func (c *jobClient) IsFailed(namespace string, name string) bool {
+	obj, err := c.Get(namespace, name)
 	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
+		return false
 	}
-	return nil
+	return c.GetStatusState(obj) == states.Failed
 }
 
-func (c *jobClient) Patch(namespace string, name string, data []byte) error {
-
-	request := c.restClient.Patch(types.JSONPatchType).
-		Resource(c.resourcePluralForm).
-		Namespace(namespace).
-		Name(name).
-		Body(data)
-
-	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
-
-	return request.Do().Error()
-}
-
-func (c *jobClient) Get(namespace, name string) (result runtime.Object, err error) {
-	result = &batchv1.Job{}
-	opts := metav1.GetOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(result)
-
-	return result, err
+func (c *jobClient) IsEphemeral() bool {
+	return false
 }
 
-func (c *jobClient) List(namespace string, labels map[string]string) (result []metav1.Object, err error) {
-	list := &batchv1.JobList{}
-	opts := metav1.ListOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(list)
-
-	if err != nil {
-		return result, err
-	}
-
-	for _, item := range list.Items {
-		// We need a copy of the item here because item has function scope whereas the copy below has a local scope.
-		// Ex: When we iterate through items, the result list will only contain multiple copies of the last item in the list.
-		jobCopy := item
-		result = append(result, &jobCopy)
-	}
-
-	return
+func (c *jobClient) GetStatusState(obj runtime.Object) states.State {
+	state, _ := c.jobStatus(obj)
+	return state
 }
 
-func (c *jobClient) Plural() string {
-	return c.resourcePluralForm
+func (c *jobClient) GetStatusReason(obj runtime.Object) string {
+	_, reason := c.jobStatus(obj)
+	return reason
 }
 
-func (c *jobClient) IsFailed(namespace string, name string) bool {
-
-	obj, err := c.Get(namespace, name)
-	if err != nil {
-		return false
+func (c *jobClient) GetProgress(obj runtime.Object) string {
+	job, ok := obj.(*batchv1.Job)
+	if !ok {
+		panic("object was not a *batchv1.Job")
 	}
-
-	return c.isFailed(obj)
+	if job.Spec.Completions == nil {
+		return fmt.Sprintf("%d completions", job.Status.Succeeded)
+	}
+	return fmt.Sprintf("%d/%d completions", job.Status.Succeeded, *job.Spec.Completions)
 }
 
-func (c *jobClient) isFailed(obj runtime.Object) bool {
+// jobStatus returns the state of the supplied job and, if it is pending or
+// failed, the reason why.
+func (c *jobClient) jobStatus(obj runtime.Object) (states.State, string) {
 	job, ok := obj.(*batchv1.Job)
 	if !ok {
-		panic("Object was not a *batchv1.Job")
+		panic("object was not a *batchv1.Job")
 	}
 
-	// We need to check the pod status before job status as the job status is not set if a container in the pod is in an error state.
-
-	// If the job is not in a failed state we inspect whether the
-	// containers controlled by the job are healthy.
-	// This is required because the definition of pod failure in kubernetes is
-	// strict. The pod is considered failed iff all containers in the pod have
-	// terminated, and at least one container has terminated in a failure (exited
-	// with a non-zero exit code or was stopped by the system).
-	podClient := NewPodClient(GlobalTemplateValues{}, c.k8sClientset, "")
-
-	// List all the pods with the same labels as the job and check if
-	// they have failed.
-	podList, err := podClient.List(job.ObjectMeta.Namespace, job.ObjectMeta.Labels)
-	if err != nil {
-		return false
-	}
-
-	for _, pod := range podList {
-		if podClient.IsFailed(pod.GetNamespace(), pod.GetName()) {
-			return true
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return states.Completed, ""
+		case batchv1.JobFailed:
+			// E.g. BackoffLimitExceeded or DeadlineExceeded.
+			return states.Failed, joinReason(condition.Reason, condition.Message)
 		}
 	}
 
-	conditions := job.Status.Conditions
-	if len(conditions) == 0 {
-		return false
-	}
-	latestCondition := conditions[0]
-	for i := range conditions {
-		time1 := &latestCondition.LastTransitionTime
-		time2 := &conditions[i].LastTransitionTime
-		if time1.Before(time2) {
-			latestCondition = conditions[i]
+	// The job controller may not have caught up with the job yet.
+	if deadline := job.Spec.ActiveDeadlineSeconds; deadline != nil && job.Status.StartTime != nil {
+		if time.Since(job.Status.StartTime.Time) > time.Duration(*deadline)*time.Second {
+			return states.Failed, fmt.Sprintf("DeadlineExceeded: job was active longer than %ds", *deadline)
 		}
 	}
 
-	if latestCondition.Type == batchv1.JobFailed {
-		return true
+	// We need to check the pod status before job status as the job status is
+	// not set if a pod can't make progress, e.g. when its image can't be
+	// pulled. Failed pods are retried by the job controller until the backoff
+	// limit is reached.
+	backoffLimit := int32(6)
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
 	}
+	failures := job.Status.Failed
 
-	return false
-}
-
-func (c *jobClient) IsEphemeral() bool {
-	return false
-}
-
-func (c *jobClient) isCompleted(obj runtime.Object) bool {
-
-	job, ok := obj.(*batchv1.Job)
-	if !ok {
-		panic("Object was not a *batchv1.Job")
+	pods, err := c.pods.list(job.Namespace, job.Spec.Selector, job.UID)
+	if err != nil {
+		return states.Running, ""
 	}
 
-	conditions := job.Status.Conditions
-	if len(conditions) == 0 {
-		return false
-	}
-	latestCondition := conditions[0]
-	for i := range conditions {
-		time1 := &latestCondition.LastTransitionTime
-		time2 := &conditions[i].LastTransitionTime
-		if time1.Before(time2) {
-			latestCondition = conditions[i]
+	running := job.Status.Succeeded > 0
+	for _, pod := range pods {
+		switch pod.Status.Phase {
+		case corev1.PodRunning, corev1.PodSucceeded:
+			running = true
+		case corev1.PodFailed:
+			// Already counted in the job status.
+			continue
+		}
+		// Containers restarted in place count towards the backoff limit.
+		for _, status := range pod.Status.ContainerStatuses {
+			failures += status.RestartCount
+		}
+		if state, reason := podState(pod); state == states.Failed && !podRetried(pod) {
+			return states.Failed, fmt.Sprintf(`pod "%s" failed: %s`, pod.Name, reason)
 		}
 	}
 
-	if latestCondition.Type == batchv1.JobComplete {
-		return true
+	if failures > backoffLimit {
+		return states.Failed, fmt.Sprintf("BackoffLimitExceeded: %d failures exceed the backoff limit of %d", failures, backoffLimit)
 	}
-	return false
-}
-
-func (c *jobClient) GetStatusState(obj runtime.Object) states.State {
-	// TODO(CD): Detect Pending, Completed and Failed states.
-
-	if c.isCompleted(obj) {
-		return states.Completed
+	if !running {
+		return states.Pending, "waiting for a pod to run"
 	}
+	return states.Running, ""
+}
 
-	if c.isFailed(obj) {
-		return states.Failed
+// podRetried returns true if the failure of the supplied pod is retried,
+// and therefore counted towards the backoff limit, by the job controller.
+// That is the case for containers that crashed, whether they are restarted
+// in place or their pod fails and is replaced, but not for pods that can't
+// start, e.g. because their image can't be pulled.
+func podRetried(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.RestartCount > 0 || status.State.Terminated != nil {
+			return true
+		}
 	}
-
-	return states.Running
+	return false
 }
@@ -0,0 +1,199 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// jobPodServer lists the supplied pods.
+func jobPodServer(t *testing.T, pods *[]corev1.Pod) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/namespace1/pods", r.URL.Path)
+		assert.Equal(t, "job-name=job1", r.URL.Query().Get("labelSelector"))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&corev1.PodList{
+			TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "PodList"},
+			Items:    *pods,
+		})
+	}))
+}
+
+func TestJobStatusState(t *testing.T) {
+	var pods []corev1.Pod
+	server := jobPodServer(t, &pods)
+	defer server.Close()
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	c := &jobClient{pods: newPodLister(clientSet)}
+
+	backoffLimit := int32(2)
+	deadline := int64(60)
+	completions := int32(3)
+	pod := func(phase corev1.PodPhase, restartPolicy corev1.RestartPolicy, statuses ...corev1.ContainerStatus) corev1.Pod {
+		controller := true
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "job1-abcde",
+				Namespace:       "namespace1",
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Job", Name: "job1", UID: "3982", Controller: &controller}},
+			},
+			Spec:   corev1.PodSpec{RestartPolicy: restartPolicy},
+			Status: corev1.PodStatus{Phase: phase, ContainerStatuses: statuses},
+		}
+	}
+
+	tests := map[string]struct {
+		spec     batchv1.JobSpec
+		status   batchv1.JobStatus
+		pods     []corev1.Pod
+		expected states.State
+		reason   string
+		progress string
+	}{
+		"complete": {
+			spec: batchv1.JobSpec{Completions: &completions},
+			status: batchv1.JobStatus{
+				Succeeded:  3,
+				Conditions: []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}},
+			},
+			expected: states.Completed,
+			progress: "3/3 completions",
+		},
+		"failed": {
+			status: batchv1.JobStatus{
+				Failed: 7,
+				Conditions: []batchv1.JobCondition{{
+					Type:    batchv1.JobFailed,
+					Status:  corev1.ConditionTrue,
+					Reason:  "BackoffLimitExceeded",
+					Message: "Job has reached the specified backoff limit",
+				}},
+			},
+			expected: states.Failed,
+			reason:   "BackoffLimitExceeded: Job has reached the specified backoff limit",
+			progress: "0 completions",
+		},
+		"deadline exceeded": {
+			spec:     batchv1.JobSpec{ActiveDeadlineSeconds: &deadline},
+			status:   batchv1.JobStatus{StartTime: &metav1.Time{Time: time.Now().Add(-2 * time.Minute)}},
+			expected: states.Failed,
+			reason:   "DeadlineExceeded: job was active longer than 60s",
+			progress: "0 completions",
+		},
+		"backoff limit exceeded": {
+			spec:     batchv1.JobSpec{BackoffLimit: &backoffLimit},
+			status:   batchv1.JobStatus{Failed: 3},
+			expected: states.Failed,
+			reason:   "BackoffLimitExceeded: 3 failures exceed the backoff limit of 2",
+			progress: "0 completions",
+		},
+		"restarted in place beyond the backoff limit": {
+			spec:   batchv1.JobSpec{BackoffLimit: &backoffLimit},
+			status: batchv1.JobStatus{Failed: 1},
+			pods: []corev1.Pod{
+				pod(corev1.PodRunning, corev1.RestartPolicyOnFailure, corev1.ContainerStatus{
+					Name:         "c1",
+					RestartCount: 2,
+					State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}),
+			},
+			expected: states.Failed,
+			reason:   "BackoffLimitExceeded: 3 failures exceed the backoff limit of 2",
+			progress: "0 completions",
+		},
+		"pod cannot start": {
+			pods: []corev1.Pod{
+				pod(corev1.PodPending, corev1.RestartPolicyNever,
+					containerStatus(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}})),
+			},
+			expected: states.Failed,
+			reason:   `pod "job1-abcde" failed: container c1: ImagePullBackOff`,
+			progress: "0 completions",
+		},
+		"pod crashed and is retried": {
+			pods: []corev1.Pod{
+				pod(corev1.PodRunning, corev1.RestartPolicyOnFailure, corev1.ContainerStatus{
+					Name:         "c1",
+					RestartCount: 1,
+					State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				}),
+			},
+			expected: states.Running,
+			progress: "0 completions",
+		},
+		"container exited and the pod is replaced": {
+			pods: []corev1.Pod{
+				pod(corev1.PodRunning, corev1.RestartPolicyNever,
+					containerStatus(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}),
+					corev1.ContainerStatus{Name: "c2", State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}),
+			},
+			expected: states.Running,
+			progress: "0 completions",
+		},
+		"waiting for a pod": {
+			pods: []corev1.Pod{
+				pod(corev1.PodPending, corev1.RestartPolicyNever),
+			},
+			expected: states.Pending,
+			reason:   "waiting for a pod to run",
+			progress: "0 completions",
+		},
+		"pod running": {
+			spec: batchv1.JobSpec{Completions: &completions},
+			pods: []corev1.Pod{
+				pod(corev1.PodRunning, corev1.RestartPolicyNever),
+			},
+			expected: states.Running,
+			progress: "0/3 completions",
+		},
+		"some completions": {
+			spec:     batchv1.JobSpec{Completions: &completions},
+			status:   batchv1.JobStatus{Succeeded: 1},
+			expected: states.Running,
+			progress: "1/3 completions",
+		},
+	}
+	for name, tc := range tests {
+		pods = tc.pods
+		tc.spec.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"job-name": "job1"}}
+		job := &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "job1", Namespace: "namespace1", UID: "3982"},
+			Spec:       tc.spec,
+			Status:     tc.status,
+		}
+		assert.Equal(t, tc.expected, c.GetStatusState(job), name)
+		assert.Equal(t, tc.reason, c.GetStatusReason(job), name)
+		assert.Equal(t, tc.progress, c.GetProgress(job), name)
+	}
+}
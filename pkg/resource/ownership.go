@@ -0,0 +1,176 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Labels set on the subresources created on behalf of a custom
+// resource.
+const (
+	// ManagedByLabel names the tool that manages the subresource.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	// InstanceLabel holds the name of the controlling custom resource. It
+	// is specific to this project, as templates may set the standard
+	// app.kubernetes.io/instance label to another value, e.g. the name of a
+	// Helm release.
+	InstanceLabel = "kubernetes.intel.com/instance"
+	// PartOfLabel holds the plural form of the controlling custom resource
+	// kind, taken from its CRD handle. The reconciler sets it on the custom
+	// resource passed as template values. Without it, the lower-case kind is
+	// used.
+	PartOfLabel = "app.kubernetes.io/part-of"
+)
+
+// ManagedBy is the value of the ManagedByLabel.
+const ManagedBy = "crd-reconciler"
+
+// TemplateChecksumAnnotation holds the checksum of the reified template a
+// subresource was last created or updated from.
+const TemplateChecksumAnnotation = "kubernetes.intel.com/template-checksum"
+
+// RejectedTemplateChecksumAnnotation holds the checksum of a reified template
+// the API server rejected updating a non-ephemeral subresource to, e.g.
+// because it changes immutable fields. The update is not retried until the
+// template changes again.
+const RejectedTemplateChecksumAnnotation = "kubernetes.intel.com/rejected-template-checksum"
+
+// TemplateChecksum returns the checksum of the supplied reified body, as
+// stored in the TemplateChecksumAnnotation.
+func TemplateChecksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// withOwnership returns the supplied reified body with a controller owner
+// reference to the custom resource passed as template values, with the
+// standard labels, and with its checksum in the TemplateChecksumAnnotation.
+// Owner references and labels set by the template are left alone. Bodies
+// annotated with RetainAnnotation get no owner reference, so that they
+// outlive the custom resource. The body is returned unchanged if the template
+// values are not a Kubernetes object.
+func withOwnership(body []byte, templateValues interface{}) ([]byte, error) {
+	owner, ok := templateValues.(runtime.Object)
+	if !ok {
+		return body, nil
+	}
+	ownerMeta, err := meta.Accessor(owner)
+	if err != nil {
+		return body, nil
+	}
+	gvk := owner.GetObjectKind().GroupVersionKind()
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	retained := annotations[RetainAnnotation] == "true"
+	annotations[TemplateChecksumAnnotation] = TemplateChecksum(body)
+	obj.SetAnnotations(annotations)
+
+	if !retained && gvk.Kind != "" && ownerMeta.GetUID() != "" && metav1.GetControllerOf(obj) == nil {
+		controller := true
+		obj.SetOwnerReferences(append(obj.GetOwnerReferences(), metav1.OwnerReference{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Name:       ownerMeta.GetName(),
+			UID:        ownerMeta.GetUID(),
+			Controller: &controller,
+		}))
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	standardLabels := map[string]string{
+		ManagedByLabel: ManagedBy,
+		InstanceLabel:  ownerMeta.GetName(),
+		PartOfLabel:    ownerMeta.GetLabels()[PartOfLabel],
+	}
+	if standardLabels[PartOfLabel] == "" {
+		standardLabels[PartOfLabel] = strings.ToLower(gvk.Kind)
+	}
+	for key, value := range standardLabels {
+		// Names can be longer than label values.
+		if len(validation.IsValidLabelValue(value)) > 0 {
+			continue
+		}
+		if _, ok := labels[key]; !ok && value != "" {
+			labels[key] = value
+		}
+	}
+	obj.SetLabels(labels)
+
+	return obj.MarshalJSON()
+}
+
+// withName returns the supplied reified body with the object renamed to the
+// supplied name. The body is returned unchanged if the name is empty.
+func withName(body []byte, name string) ([]byte, error) {
+	if name == "" {
+		return body, nil
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+	obj.SetName(name)
+	return obj.MarshalJSON()
+}
+
+// withAPIVersion returns the supplied reified body with the object converted
+// to the supplied API version. The API versions of deployments, daemon sets
+// and stateful sets that preceded apps/v1beta2 defaulted the selector to the
+// labels of the pod template, which is done here for bodies of another API
+// version that have no selector.
+func withAPIVersion(body []byte, groupVersion schema.GroupVersion) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+	if obj.GetAPIVersion() == groupVersion.String() {
+		return body, nil
+	}
+	obj.SetAPIVersion(groupVersion.String())
+
+	spec, _ := obj.Object["spec"].(map[string]interface{})
+	template, _ := spec["template"].(map[string]interface{})
+	metadata, _ := template["metadata"].(map[string]interface{})
+	labels, _ := metadata["labels"].(map[string]interface{})
+	if _, ok := spec["selector"]; !ok && len(labels) > 0 {
+		spec["selector"] = map[string]interface{}{"matchLabels": labels}
+	}
+	return obj.MarshalJSON()
+}
@@ -0,0 +1,146 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+func TestWithOwnership(t *testing.T) {
+	owner := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kubernetes.intel.com/v1", Kind: "CRDKind1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cr1", UID: "3982"},
+	}
+	tests := map[string]struct {
+		body            string
+		ownerReferences int
+	}{
+		"owned": {
+			body:            `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"claim1"}}`,
+			ownerReferences: 1,
+		},
+		"retained": {
+			body:            `{"apiVersion":"v1","kind":"PersistentVolumeClaim","metadata":{"name":"claim1","annotations":{"kubernetes.intel.com/retain-on-delete":"true"}}}`,
+			ownerReferences: 0,
+		},
+	}
+	for name, tc := range tests {
+		body, err := withOwnership([]byte(tc.body), owner)
+		require.NoError(t, err, name)
+		obj := &unstructured.Unstructured{}
+		require.NoError(t, obj.UnmarshalJSON(body), name)
+
+		assert.Len(t, obj.GetOwnerReferences(), tc.ownerReferences, name)
+		assert.Equal(t, map[string]string{
+			ManagedByLabel: ManagedBy,
+			InstanceLabel:  "cr1",
+			PartOfLabel:    "crdkind1",
+		}, obj.GetLabels(), name)
+		assert.Equal(t, TemplateChecksum([]byte(tc.body)), obj.GetAnnotations()[TemplateChecksumAnnotation], name)
+	}
+}
+
+func TestWithOwnershipLabels(t *testing.T) {
+	body := []byte(`{"apiVersion":"v1","kind":"Service","metadata":{"name":"service1"}}`)
+	tests := map[string]struct {
+		objectMeta metav1.ObjectMeta
+		expected   map[string]string
+	}{
+		"part-of from the owner": {
+			objectMeta: metav1.ObjectMeta{Name: "cr1", Labels: map[string]string{PartOfLabel: "crdkind1s"}},
+			expected: map[string]string{
+				ManagedByLabel: ManagedBy,
+				InstanceLabel:  "cr1",
+				PartOfLabel:    "crdkind1s",
+			},
+		},
+		"name too long for a label": {
+			objectMeta: metav1.ObjectMeta{Name: strings.Repeat("a", 64)},
+			expected: map[string]string{
+				ManagedByLabel: ManagedBy,
+				PartOfLabel:    "crdkind1",
+			},
+		},
+	}
+	for name, tc := range tests {
+		owner := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "kubernetes.intel.com/v1", Kind: "CRDKind1"},
+			ObjectMeta: tc.objectMeta,
+		}
+		result, err := withOwnership(body, owner)
+		require.NoError(t, err, name)
+		obj := &unstructured.Unstructured{}
+		require.NoError(t, obj.UnmarshalJSON(result), name)
+		assert.Equal(t, tc.expected, obj.GetLabels(), name)
+	}
+}
+
+func TestApplyOwnership(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apply")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	templateFileName := filepath.Join(dir, "service.yaml")
+	require.NoError(t, ioutil.WriteFile(templateFileName, []byte("apiVersion: v1\nkind: Service\nmetadata:\n  name: {{.Name}}\n"), 0644))
+
+	var applied []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "PATCH", r.Method)
+		assert.Equal(t, "/api/v1/namespaces/namespace1/services/cr1-service", r.URL.Path)
+		applied = body
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	c, err := NewUnstructuredClient(GlobalTemplateValues{}, &rest.Config{Host: server.URL}, UnstructuredResource{
+		GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "services"},
+		Kind:                 "Service",
+	}, templateFileName)
+	require.NoError(t, err)
+	owner := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "kubernetes.intel.com/v1", Kind: "CRDKind1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cr1", UID: "3982"},
+	}
+	_, err = c.Apply("namespace1", "cr1-service", owner, ApplyOptions{})
+	require.NoError(t, err)
+
+	obj := &unstructured.Unstructured{}
+	require.NoError(t, obj.UnmarshalJSON(applied))
+	assert.Equal(t, "cr1-service", obj.GetName())
+	assert.Equal(t, "cr1", obj.GetLabels()[InstanceLabel])
+	controllerRef := metav1.GetControllerOf(obj)
+	require.NotNil(t, controllerRef)
+	assert.Equal(t, "cr1", controllerRef.Name)
+}
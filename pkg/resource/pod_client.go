@@ -19,208 +19,246 @@
 package resource
 
 import (
+	"context"
 	"fmt"
-	"net/http"
+	"sync"
+	"time"
 
-	"github.com/golang/glog"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	apilabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 
-	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
+// podFailureReasons are the reasons for a waiting container that mean the
+// pod will not make progress without intervention.
+var podFailureReasons = map[string]bool{
+	"ImagePullBackOff": true,
+	"CrashLoopBackOff": true,
+}
+
+// podUnschedulableTimeout is how long a pod can remain unschedulable before
+// it is considered failed.
+var podUnschedulableTimeout = 5 * time.Minute
+
 type podClient struct {
-	globalTemplateValues GlobalTemplateValues
-	restClient           rest.Interface
-	resourcePluralForm   string
-	templateFileName     string
+	baseClient
 }
 
 // NewPodClient returns a new pod client.
 func NewPodClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, templateFileName string) Client {
-	return &podClient{
-		globalTemplateValues: globalTemplateValues,
-		restClient:           clientSet.CoreV1().RESTClient(),
-		resourcePluralForm:   "pods",
-		templateFileName:     templateFileName,
-	}
+	return newPodClient(globalTemplateValues, clientSet.CoreV1().RESTClient(), templateFileName)
 }
 
-func (c *podClient) Reify(templateValues interface{}) ([]byte, error) {
-	result, err := reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
-	if err != nil {
-		return nil, err
+// newPodClient returns a pod client that sends its requests with the
+// supplied REST client.
+func newPodClient(globalTemplateValues GlobalTemplateValues, restClient rest.Interface, templateFileName string) *podClient {
+	return &podClient{
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           restClient,
+			resourcePluralForm:   "pods",
+			templateFileName:     templateFileName,
+			newObject:            func() runtime.Object { return &corev1.Pod{} },
+			newList:              func() runtime.Object { return &corev1.PodList{} },
+		},
 	}
-	return result, nil
 }
 
-func (c *podClient) Create(namespace string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Post().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
+func (c *podClient) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	return &clientCopy
+}
 
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
+func (c *podClient) IsEphemeral() bool {
+	return true
+}
 
+func (c *podClient) IsFailed(namespace string, name string) bool {
+	p, err := c.Get(namespace, name)
 	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
+		return false
 	}
-	return nil
+	return c.isFailed(p)
 }
 
-func (c *podClient) Delete(namespace, name string) error {
-	request := c.restClient.Delete().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name)
+func (c *podClient) isFailed(obj runtime.Object) bool {
+	state, _ := podState(asPod(obj))
+	return state == states.Failed
+}
 
-	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
+func (c *podClient) GetStatusState(obj runtime.Object) states.State {
+	state, _ := podState(asPod(obj))
+	return state
+}
 
-	return request.Do().Error()
+func (c *podClient) GetStatusReason(obj runtime.Object) string {
+	_, reason := podState(asPod(obj))
+	return reason
 }
 
-func (c *podClient) Update(namespace string, name string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
+func asPod(obj runtime.Object) *corev1.Pod {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		panic("object was not a *corev1.Pod")
 	}
+	return pod
+}
 
-	request := c.restClient.Put().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
+// listControlledPods returns the pods selected by the supplied label
+// selector, listed with the supplied pod client. If controllerUID is not
+// empty, only the pods controlled by the object with that UID are returned.
+// The status of the returned pods can be evaluated directly, without getting
+// each pod again.
+func listControlledPods(podClient Client, namespace string, selector *metav1.LabelSelector, controllerUID types.UID) ([]*corev1.Pod, error) {
+	// Never list the whole namespace.
+	if selector == nil || len(selector.MatchLabels) == 0 {
+		return nil, nil
+	}
+	podList, err := podClient.List(namespace, selector.MatchLabels)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
+
+	var result []*corev1.Pod
+	for _, obj := range podList {
+		if controllerUID != "" {
+			controllerRef := metav1.GetControllerOf(obj)
+			if controllerRef == nil || controllerRef.UID != controllerUID {
+				continue
+			}
+		}
+		if pod, ok := obj.(*corev1.Pod); ok {
+			result = append(result, pod)
+		}
 	}
-	return nil
+	return result, nil
 }
 
-func (c *podClient) Patch(namespace string, name string, data []byte) error {
-
-	request := c.restClient.Patch(types.JSONPatchType).
-		Resource(c.resourcePluralForm).
-		Namespace(namespace).
-		Name(name).
-		Body(data)
-
-	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
-
-	return request.Do().Error()
+// podLister lists the pods controlled by subresources, from shared informers
+// if it has them. A lister bound to a context, i.e. to a reconcile pass,
+// lists the pods of each controller once and serves later calls from that
+// listing.
+type podLister struct {
+	clientSet kubernetes.Interface
+	informers *informer.Cache
+	ctx       context.Context
+	mutex     sync.Mutex
+	listed    map[string][]*corev1.Pod
 }
 
-func (c *podClient) Get(namespace, name string) (result runtime.Object, err error) {
-	result = &corev1.Pod{}
-	opts := metav1.GetOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(result)
+func newPodLister(clientSet kubernetes.Interface) *podLister {
+	return &podLister{clientSet: clientSet}
+}
 
-	return result, err
+// withContext returns a lister bound to the supplied context, with nothing
+// listed yet.
+func (l *podLister) withContext(ctx context.Context) *podLister {
+	return &podLister{clientSet: l.clientSet, informers: l.informers, ctx: ctx, listed: map[string][]*corev1.Pod{}}
 }
 
-func (c *podClient) List(namespace string, labels map[string]string) (result []metav1.Object, err error) {
-	list := &corev1.PodList{}
+// withInformers returns a lister that lists pods from the informers shared
+// through the supplied cache.
+func (l *podLister) withInformers(informers *informer.Cache) *podLister {
+	return &podLister{clientSet: l.clientSet, informers: informers, ctx: l.ctx, listed: l.listed}
+}
 
-	opts := metav1.ListOptions{}
-	if len(labels) > 0 {
-		selector := apilabels.SelectorFromSet(apilabels.Set(labels))
-		opts = metav1.ListOptions{LabelSelector: selector.String()}
+// podClient returns the client that lists the pods.
+func (l *podLister) podClient() Client {
+	var podClient Client = newPodClient(nil, l.clientSet.CoreV1().RESTClient(), "").WithContext(l.ctx)
+	if l.informers != nil {
+		podClient = NewCachedClient(podClient, l.informers)
 	}
+	return podClient
+}
 
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(list)
-
-	if err != nil {
-		return []metav1.Object{}, err
+// list is like listControlledPods.
+func (l *podLister) list(namespace string, selector *metav1.LabelSelector, controllerUID types.UID) ([]*corev1.Pod, error) {
+	if selector == nil || len(selector.MatchLabels) == 0 {
+		return nil, nil
 	}
-
-	for _, item := range list.Items {
-		// We need a copy of the item here because item has function scope whereas the copy below has a local scope.
-		// Ex: When we iterate through items, the result list will only contain multiple copies of the last item in the list.
-		podCopy := item
-		result = append(result, &podCopy)
+	if l.listed == nil {
+		return listControlledPods(l.podClient(), namespace, selector, controllerUID)
 	}
 
-	return
-}
-
-func (c *podClient) IsEphemeral() bool {
-	return true
+	key := fmt.Sprintf("%s/%s/%s", namespace, metav1.FormatLabelSelector(selector), controllerUID)
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if pods, ok := l.listed[key]; ok {
+		return pods, nil
+	}
+	pods, err := listControlledPods(l.podClient(), namespace, selector, controllerUID)
+	if err != nil {
+		return nil, err
+	}
+	l.listed[key] = pods
+	return pods, nil
 }
 
-func (c *podClient) Plural() string {
-	return c.resourcePluralForm
-}
+// podState returns the state of the supplied pod and, if it is pending or
+// failed, the reason why.
+func podState(pod *corev1.Pod) (states.State, string) {
+	switch pod.Status.Phase {
+	case corev1.PodSucceeded:
+		return states.Completed, ""
+	case corev1.PodFailed:
+		// Evicted pods end up here, with the eviction as the reason.
+		return states.Failed, joinReason(pod.Status.Reason, pod.Status.Message)
+	}
 
-func (c *podClient) IsFailed(namespace string, name string) bool {
-	p, err := c.Get(namespace, name)
-	if err != nil {
-		return false
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil && podFailureReasons[waiting.Reason] {
+			return states.Failed, fmt.Sprintf("container %s: %s", status.Name, joinReason(waiting.Reason, waiting.Message))
+		}
+		if status.Ready {
+			continue
+		}
+		if terminated := status.State.Terminated; terminated != nil {
+			if terminated.Reason == "OOMKilled" || pod.Spec.RestartPolicy == corev1.RestartPolicyNever && terminated.ExitCode != 0 {
+				return states.Failed, fmt.Sprintf("container %s: %s", status.Name, joinReason(terminated.Reason, terminated.Message))
+			}
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.Reason == "OOMKilled" {
+			return states.Failed, fmt.Sprintf("container %s: %s", status.Name, joinReason(terminated.Reason, terminated.Message))
+		}
 	}
-	return c.isFailed(p)
-}
 
-func (c *podClient) isFailed(obj runtime.Object) bool {
-	pod, ok := obj.(*corev1.Pod)
-	if !ok {
-		panic("object was not a *corev1.Pod")
+	if pod.Status.Phase != corev1.PodPending {
+		return states.Running, ""
 	}
-	if pod.Status.Phase == corev1.PodFailed {
-		return true
+
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type != corev1.PodScheduled || condition.Status != corev1.ConditionFalse {
+			continue
+		}
+		reason := joinReason(condition.Reason, condition.Message)
+		if condition.Reason == corev1.PodReasonUnschedulable && time.Since(condition.LastTransitionTime.Time) > podUnschedulableTimeout {
+			return states.Failed, reason
+		}
+		return states.Pending, reason
 	}
 	for _, status := range pod.Status.ContainerStatuses {
-		if !status.Ready && status.RestartCount > 0 {
-			return true
-		}
-		// Indicates that a container in the pod was terminated with a non-zero exit code
-		if !status.Ready && status.State.Terminated != nil && status.State.Terminated.ExitCode > 0 {
-			return true
+		if waiting := status.State.Waiting; waiting != nil {
+			// E.g. ContainerCreating.
+			return states.Pending, fmt.Sprintf("container %s: %s", status.Name, joinReason(waiting.Reason, waiting.Message))
 		}
 	}
-	return false
+	return states.Pending, ""
 }
 
-func (c *podClient) GetStatusState(obj runtime.Object) states.State {
-	if c.isFailed(obj) {
-		return states.Failed
+// joinReason joins a reason and a message reported by Kubernetes.
+func joinReason(reason, message string) string {
+	if message == "" {
+		return reason
 	}
-
-	// TODO(CD): Detect Pending, Completed and Failed states.
-	return states.Running
+	if reason == "" {
+		return message
+	}
+	return reason + ": " + message
 }
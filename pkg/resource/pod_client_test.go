@@ -0,0 +1,190 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// containerStatus returns the status of a container named c1 in the
+// supplied state.
+func containerStatus(state corev1.ContainerState) corev1.ContainerStatus {
+	return corev1.ContainerStatus{Name: "c1", State: state}
+}
+
+func TestPodStatusState(t *testing.T) {
+	tests := map[string]struct {
+		restartPolicy corev1.RestartPolicy
+		status        corev1.PodStatus
+		expected      states.State
+		reason        string
+	}{
+		"succeeded": {
+			status:   corev1.PodStatus{Phase: corev1.PodSucceeded},
+			expected: states.Completed,
+		},
+		"evicted": {
+			status:   corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted", Message: "The node was low on resource: memory."},
+			expected: states.Failed,
+			reason:   "Evicted: The node was low on resource: memory.",
+		},
+		"image pull back-off": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{
+					containerStatus(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image"}}),
+				},
+			},
+			expected: states.Failed,
+			reason:   "container c1: ImagePullBackOff: Back-off pulling image",
+		},
+		"crash loop back-off": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					containerStatus(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}),
+				},
+			},
+			expected: states.Failed,
+			reason:   "container c1: CrashLoopBackOff",
+		},
+		"out of memory": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					containerStatus(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}}),
+				},
+			},
+			expected: states.Failed,
+			reason:   "container c1: OOMKilled",
+		},
+		"restarted after running out of memory": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "c1",
+					State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+				}},
+			},
+			expected: states.Failed,
+			reason:   "container c1: OOMKilled",
+		},
+		"exited without restart": {
+			restartPolicy: corev1.RestartPolicyNever,
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					containerStatus(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}),
+				},
+			},
+			expected: states.Failed,
+			reason:   "container c1: Error",
+		},
+		"exited with restart": {
+			restartPolicy: corev1.RestartPolicyOnFailure,
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					containerStatus(corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1}}),
+				},
+			},
+			expected: states.Running,
+		},
+		"running": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:  "c1",
+					Ready: true,
+					State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				}},
+			},
+			expected: states.Running,
+		},
+		"unschedulable": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodScheduled,
+					Status:             corev1.ConditionFalse,
+					Reason:             corev1.PodReasonUnschedulable,
+					Message:            "0/3 nodes are available",
+					LastTransitionTime: metav1.Now(),
+				}},
+			},
+			expected: states.Pending,
+			reason:   "Unschedulable: 0/3 nodes are available",
+		},
+		"unschedulable for too long": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				Conditions: []corev1.PodCondition{{
+					Type:               corev1.PodScheduled,
+					Status:             corev1.ConditionFalse,
+					Reason:             corev1.PodReasonUnschedulable,
+					Message:            "0/3 nodes are available",
+					LastTransitionTime: metav1.NewTime(time.Now().Add(-2 * podUnschedulableTimeout)),
+				}},
+			},
+			expected: states.Failed,
+			reason:   "Unschedulable: 0/3 nodes are available",
+		},
+		"creating containers": {
+			status: corev1.PodStatus{
+				Phase: corev1.PodPending,
+				ContainerStatuses: []corev1.ContainerStatus{
+					containerStatus(corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}),
+				},
+			},
+			expected: states.Pending,
+			reason:   "container c1: ContainerCreating",
+		},
+		"pending": {
+			status:   corev1.PodStatus{Phase: corev1.PodPending},
+			expected: states.Pending,
+		},
+	}
+
+	c := &podClient{}
+	for name, tc := range tests {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "namespace1"},
+			Spec:       corev1.PodSpec{RestartPolicy: tc.restartPolicy},
+			Status:     tc.status,
+		}
+		assert.Equal(t, tc.expected, c.GetStatusState(pod), name)
+		assert.Equal(t, tc.reason, c.GetStatusReason(pod), name)
+		assert.Equal(t, tc.expected == states.Failed, c.isFailed(pod), name)
+	}
+}
+
+func TestJoinReason(t *testing.T) {
+	assert.Equal(t, "Evicted: low on memory", joinReason("Evicted", "low on memory"))
+	assert.Equal(t, "Evicted", joinReason("Evicted", ""))
+	assert.Equal(t, "low on memory", joinReason("", "low on memory"))
+	assert.Equal(t, "", joinReason("", ""))
+}
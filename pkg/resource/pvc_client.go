@@ -0,0 +1,199 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/golang/glog"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+type persistentVolumeClaimClient struct {
+	*unstructuredClient
+}
+
+// PersistentVolumeClaimOptions configures persistent volume claim clients.
+type PersistentVolumeClaimOptions struct {
+	// Ephemeral claims are recreated when they are lost, e.g. claims for
+	// caches whose data can be regenerated. Losing any other claim loses its
+	// data and fails the custom resource.
+	Ephemeral bool
+}
+
+// NewPersistentVolumeClaimClient returns a new persistent volume claim client
+// for claims that are not ephemeral.
+func NewPersistentVolumeClaimClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string) (Client, error) {
+	return NewPersistentVolumeClaimClientWithOptions(globalTemplateValues, config, templateFileName, PersistentVolumeClaimOptions{})
+}
+
+// NewPersistentVolumeClaimClientWithOptions returns a new persistent volume
+// claim client configured with the supplied options.
+//
+// Claims annotated with RetainAnnotation outlive their custom resource. They
+// are created without an owner reference, so that the garbage collector
+// leaves them alone, and are released instead of deleted. Creating a claim
+// with the name of a released one, e.g. when the custom resource is
+// restarted or recreated, adopts the released claim and its data.
+func NewPersistentVolumeClaimClientWithOptions(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string, options PersistentVolumeClaimOptions) (Client, error) {
+	c, err := newUnstructuredClient(globalTemplateValues, config, UnstructuredResource{
+		GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "persistentvolumeclaims"},
+		Kind:                 "PersistentVolumeClaim",
+		Ephemeral:            options.Ephemeral,
+	}, templateFileName)
+	if err != nil {
+		return nil, err
+	}
+	return &persistentVolumeClaimClient{unstructuredClient: c}, nil
+}
+
+func (c *persistentVolumeClaimClient) WithContext(ctx context.Context) Client {
+	return &persistentVolumeClaimClient{unstructuredClient: c.withContext(ctx)}
+}
+
+// persistentVolumeClaim returns the supplied object as a persistent volume
+// claim.
+func persistentVolumeClaim(obj runtime.Object) *corev1.PersistentVolumeClaim {
+	claim := &corev1.PersistentVolumeClaim{}
+	if err := fromUnstructured(obj, claim); err != nil {
+		panic(fmt.Sprintf("object was not a persistent volume claim: %v", err))
+	}
+	return claim
+}
+
+// isRetained returns true if the supplied object is annotated with
+// RetainAnnotation.
+func isRetained(obj metav1.Object) bool {
+	return obj.GetAnnotations()[RetainAnnotation] == "true"
+}
+
+func (c *persistentVolumeClaimClient) Create(namespace string, templateValues interface{}) error {
+	_, err := c.CreateNamed(namespace, "", templateValues)
+	return err
+}
+
+func (c *persistentVolumeClaimClient) CreateObject(namespace string, templateValues interface{}) (runtime.Object, error) {
+	return c.CreateNamed(namespace, "", templateValues)
+}
+
+// CreateNamed creates the claim. A released claim of the same name is adopted
+// if the claim is retained.
+func (c *persistentVolumeClaimClient) CreateNamed(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	resourceBody, err := c.Reify(templateValues)
+	if err != nil {
+		return nil, err
+	}
+	resourceBody, err = withOwnership(resourceBody, templateValues)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := c.create(namespace, name, resourceBody)
+	if !apierrors.IsAlreadyExists(err) {
+		return obj, err
+	}
+
+	claim := &unstructured.Unstructured{}
+	if decodeErr := claim.UnmarshalJSON(resourceBody); decodeErr != nil || !isRetained(claim) {
+		return nil, err
+	}
+	if name != "" {
+		claim.SetName(name)
+	}
+	return c.adopt(namespace, claim, err)
+}
+
+// adopt labels the existing claim named like the supplied one for the custom
+// resource that the supplied claim is created for. The supplied error is
+// returned if the existing claim is not a released retained claim.
+func (c *persistentVolumeClaimClient) adopt(namespace string, claim *unstructured.Unstructured, alreadyExists error) (runtime.Object, error) {
+	obj, err := c.Get(namespace, claim.GetName())
+	if err != nil {
+		return nil, alreadyExists
+	}
+	existing, err := meta.Accessor(obj)
+	if err != nil {
+		return nil, alreadyExists
+	}
+	if !isRetained(existing) || metav1.GetControllerOf(existing) != nil || existing.GetLabels()[InstanceLabel] != "" {
+		return nil, alreadyExists
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": claim.GetLabels()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	glog.Infof(`adopting retained persistent volume claim "%s" in namespace "%s"`, claim.GetName(), namespace)
+	return c.PatchObject(namespace, claim.GetName(), types.MergePatchType, patch)
+}
+
+// Delete deletes the claim, unless it is annotated with RetainAnnotation. In
+// that case the claim is released instead: its owner references and instance
+// label are removed, so that neither the reconciler nor the garbage collector
+// deletes the claim and its data.
+func (c *persistentVolumeClaimClient) Delete(namespace, name string) error {
+	obj, err := c.Get(namespace, name)
+	if err != nil {
+		return err
+	}
+	if isRetained(persistentVolumeClaim(obj)) {
+		glog.Infof(`releasing retained persistent volume claim "%s" in namespace "%s"`, name, namespace)
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"ownerReferences": nil,
+				"labels":          map[string]interface{}{InstanceLabel: nil},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		_, err = c.PatchObject(namespace, name, types.MergePatchType, patch)
+		return err
+	}
+	return c.unstructuredClient.Delete(namespace, name)
+}
+
+func (c *persistentVolumeClaimClient) IsFailed(namespace string, name string) bool {
+	return isFailed(c, namespace, name)
+}
+
+func (c *persistentVolumeClaimClient) GetStatusState(obj runtime.Object) states.State {
+	claim := persistentVolumeClaim(obj)
+	switch claim.Status.Phase {
+	case corev1.ClaimBound:
+		return states.Running
+	case corev1.ClaimLost:
+		return states.Failed
+	}
+	// Completed doesn't make sense for this type.
+	return states.Pending
+}
@@ -20,6 +20,9 @@ package reify
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"html/template"
 	"path/filepath"
 
@@ -31,6 +34,55 @@ import (
 // Reify returns the resulting JSON by expanding the template using the
 // supplied data.
 func Reify(templateFileName string, templateValues interface{}, globalTemplateValues map[string]string) (json []byte, err error) {
+	return reify(templateFileName, templateValues, globalTemplateValues, nil, logResult)
+}
+
+// ReifySensitive returns the resulting JSON by expanding the template using
+// the supplied data and additional template functions. Unlike Reify, it never
+// logs the template values or the result, so it is suitable for templates
+// that contain credentials.
+func ReifySensitive(templateFileName string, templateValues interface{}, globalTemplateValues map[string]string, funcs template.FuncMap) (json []byte, err error) {
+	return reify(templateFileName, templateValues, globalTemplateValues, funcs, logFileName)
+}
+
+// Render is like ReifySensitive, but logs nothing at all. It suits templates
+// that are reified on every reconcile pass only to compare their checksum.
+func Render(templateFileName string, templateValues interface{}, globalTemplateValues map[string]string, funcs template.FuncMap) (json []byte, err error) {
+	return reify(templateFileName, templateValues, globalTemplateValues, funcs, logNothing)
+}
+
+// Checksum returns the SHA-256 checksum of the JSON obtained by expanding the
+// template using the supplied data. Templates can embed the checksum of
+// another template with the Checksum function, e.g. to roll the pods of a
+// deployment when the config map they mount changes:
+//
+//	checksum/config: {{ Checksum "/etc/templates/configmap.yaml" }}
+func Checksum(templateFileName string, templateValues interface{}, globalTemplateValues map[string]string) (string, error) {
+	return checksum(templateFileName, templateValues, globalTemplateValues, logFileName)
+}
+
+func checksum(templateFileName string, templateValues interface{}, globalTemplateValues map[string]string, log logging) (string, error) {
+	json, err := reify(templateFileName, templateValues, globalTemplateValues, nil, log)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(json)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// logging says what reify logs once the template is reified.
+type logging int
+
+const (
+	// logResult logs the template values and the reified template.
+	logResult logging = iota
+	// logFileName logs the name of the template file only.
+	logFileName
+	// logNothing logs nothing.
+	logNothing
+)
+
+func reify(templateFileName string, templateValues interface{}, globalTemplateValues map[string]string, funcs template.FuncMap, log logging) (json []byte, err error) {
 	// Due to a weird quirk of go templates, we must pass the base name of the
 	// template file to template.New otherwise execute can fail!
 	baseFileName := filepath.Base(templateFileName)
@@ -41,7 +93,22 @@ func Reify(templateFileName string, templateValues interface{}, globalTemplateVa
 		"GlobalTemplateValue": func(key string) string {
 			return globalTemplateValues[key]
 		},
+		// Base64 returns template.HTML so that the padding and the plus
+		// signs are not escaped.
+		"Base64": func(value string) template.HTML {
+			return template.HTML(base64.StdEncoding.EncodeToString([]byte(value)))
+		},
+		"Checksum": func(fileName string) (string, error) {
+			// Checksums embedded in silent renders are silent too.
+			if log == logNothing {
+				return checksum(fileName, templateValues, globalTemplateValues, logNothing)
+			}
+			return Checksum(fileName, templateValues, globalTemplateValues)
+		},
 	})
+	if funcs != nil {
+		tmpl = tmpl.Funcs(funcs)
+	}
 	tmpl, err = tmpl.ParseFiles(templateFileName)
 	if err != nil {
 		glog.Warningf("[reify] error parsing template file: %v", err)
@@ -57,7 +124,12 @@ func Reify(templateFileName string, templateValues interface{}, globalTemplateVa
 	// Translate YAML to JSON.
 	json, err = yaml.YAMLToJSON(buf.Bytes())
 
-	glog.Infof("reified template [%s] with data [%v]:\nYAML:\n%s\n\nJSON:\n%s", templateFileName, templateValues, buf.String(), string(json))
+	switch log {
+	case logResult:
+		glog.Infof("reified template [%s] with data [%v]:\nYAML:\n%s\n\nJSON:\n%s", templateFileName, templateValues, buf.String(), string(json))
+	case logFileName:
+		glog.Infof("reified template [%s]", templateFileName)
+	}
 
 	return
 }
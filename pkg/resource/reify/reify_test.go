@@ -80,6 +80,14 @@ func TestReify(t *testing.T) {
 			expectedError:        nil,
 			expectedResult:       `{"amount":"250m"}`,
 		},
+		{
+			description:          "base64 encoding",
+			template:             `"data": "{{ Base64 .X }}"`,
+			templateValues:       struct{ X string }{"~~~?"},
+			globalTemplateValues: map[string]string{},
+			expectedError:        nil,
+			expectedResult:       `{"data":"fn5+Pw=="}`,
+		},
 
 		// Invalid cases.
 		{
@@ -136,3 +144,55 @@ func TestReify(t *testing.T) {
 		}
 	}
 }
+
+func TestChecksum(t *testing.T) {
+	configFile, err := ioutil.TempFile("", "TestChecksum-config")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(configFile.Name())
+	_, err = configFile.WriteString(`data: {{ .X }}`)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	deploymentFile, err := ioutil.TempFile("", "TestChecksum-deployment")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(deploymentFile.Name())
+	_, err = deploymentFile.WriteString(fmt.Sprintf(`checksum: {{ Checksum "%s" }}`, configFile.Name()))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	checksumA, err := Checksum(configFile.Name(), struct{ X string }{"a"}, map[string]string{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	checksumB, err := Checksum(configFile.Name(), struct{ X string }{"b"}, map[string]string{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if checksumA == checksumB {
+		t.Errorf("expected different checksums for different values but got [%s]", checksumA)
+	}
+
+	result, err := Reify(deploymentFile.Name(), struct{ X string }{"a"}, map[string]string{})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := fmt.Sprintf(`{"checksum":"%s"}`, checksumA)
+	if string(result) != expected {
+		t.Errorf("expected result:\n%s\n\nbut got:\n%s\n", expected, string(result))
+	}
+
+	// Rendering without logging reifies the same template.
+	result, err = Render(deploymentFile.Name(), struct{ X string }{"a"}, map[string]string{}, nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(result) != expected {
+		t.Errorf("expected rendered result:\n%s\n\nbut got:\n%s\n", expected, string(result))
+	}
+}
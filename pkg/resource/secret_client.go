@@ -0,0 +1,85 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+// NewSecretClient returns a new secret client. In addition to the usual
+// template functions, secret templates can use GeneratedValue to insert a
+// random value:
+//
+//	password: {{ GeneratedValue "password" 32 | Base64 }}
+//
+// Generated values are derived from the supplied seed, the UID of the
+// custom resource and the key, so they stay the same across reconciles,
+// controller restarts and when the secret is recreated. The seed is required
+// and should itself be kept in a secret mounted into the controller. Reified
+// secrets are never logged. A secret is usable as soon as it exists, and can
+// be safely recreated.
+func NewSecretClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string, seed []byte) (Client, error) {
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("no seed supplied for secret template %s", templateFileName)
+	}
+	c, err := newUnstructuredClient(globalTemplateValues, config, UnstructuredResource{
+		GroupVersionResource: schema.GroupVersionResource{Version: "v1", Resource: "secrets"},
+		Kind:                 "Secret",
+		Ephemeral:            true,
+	}, templateFileName)
+	if err != nil {
+		return nil, err
+	}
+	c.templateFuncs = func(templateValues interface{}) template.FuncMap {
+		return template.FuncMap{
+			"GeneratedValue": func(key string, length int) string {
+				return generatedValue(seed, templateValues, key, length)
+			},
+		}
+	}
+	return c, nil
+}
+
+// generatedValue returns a random-looking value of the supplied length that
+// is stable for the supplied seed, template values and key.
+func generatedValue(seed []byte, templateValues interface{}, key string, length int) string {
+	owner := ""
+	if objMeta, err := meta.Accessor(templateValues); err == nil {
+		owner = string(objMeta.GetUID())
+		if owner == "" {
+			owner = objMeta.GetNamespace() + "/" + objMeta.GetName()
+		}
+	}
+
+	var value []byte
+	for counter := 0; len(value) < length; counter++ {
+		mac := hmac.New(sha256.New, seed)
+		fmt.Fprintf(mac, "%s/%s/%d", owner, key, counter)
+		value = append(value, base64.RawURLEncoding.EncodeToString(mac.Sum(nil))...)
+	}
+	return string(value[:length])
+}
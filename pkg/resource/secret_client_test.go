@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+const secretTemplate = `apiVersion: v1
+kind: Secret
+metadata:
+  name: {{.Name}}
+data:
+  password: {{ GeneratedValue "password" 32 | Base64 }}
+`
+
+// secretServer records the secrets created through it.
+func secretServer(t *testing.T, created *[][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/v1/namespaces/namespace1/secrets", r.URL.Path)
+		*created = append(*created, body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	}))
+}
+
+func TestSecretClientRecreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secret")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	templateFileName := filepath.Join(dir, "secret.yaml")
+	require.NoError(t, ioutil.WriteFile(templateFileName, []byte(secretTemplate), 0644))
+
+	var created [][]byte
+	server := secretServer(t, &created)
+	defer server.Close()
+
+	cr := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cr1", UID: "3982"},
+	}
+	otherCR := cr.DeepCopy()
+	otherCR.UID = "4711"
+
+	// Each client stands for a controller process, e.g. before and after a
+	// restart.
+	password := func(seed string, owner *corev1.ConfigMap) []byte {
+		c, err := NewSecretClient(GlobalTemplateValues{}, &rest.Config{Host: server.URL}, templateFileName, []byte(seed))
+		require.NoError(t, err)
+		_, err = c.CreateObject("namespace1", owner)
+		require.NoError(t, err)
+		secret := &corev1.Secret{}
+		require.NoError(t, json.Unmarshal(created[len(created)-1], secret))
+		return secret.Data["password"]
+	}
+
+	first := password("seed", cr)
+	assert.Len(t, first, 32)
+	assert.Equal(t, first, password("seed", cr))
+	assert.NotEqual(t, first, password("other seed", cr))
+	assert.NotEqual(t, first, password("seed", otherCR))
+}
+
+func TestSecretClientRequiresSeed(t *testing.T) {
+	_, err := NewSecretClient(GlobalTemplateValues{}, &rest.Config{Host: "localhost"}, "secret.yaml", nil)
+	assert.Error(t, err)
+}
@@ -19,173 +19,209 @@
 package resource
 
 import (
+	"context"
 	"fmt"
-	"net/http"
 
-	"github.com/golang/glog"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
-	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 
-	"github.com/intel/crd-reconciler-for-kubernetes/pkg/resource/reify"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
 	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
 )
 
 type serviceClient struct {
-	globalTemplateValues GlobalTemplateValues
-	restClient           rest.Interface
-	resourcePluralForm   string
-	templateFileName     string
+	baseClient
+	informers *informer.Cache
 }
 
 // NewServiceClient returns a new service client.
 func NewServiceClient(globalTemplateValues GlobalTemplateValues, clientSet *kubernetes.Clientset, templateFileName string) Client {
 	return &serviceClient{
-		globalTemplateValues: globalTemplateValues,
-		restClient:           clientSet.CoreV1().RESTClient(),
-		resourcePluralForm:   "services",
-		templateFileName:     templateFileName,
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           clientSet.CoreV1().RESTClient(),
+			resourcePluralForm:   "services",
+			templateFileName:     templateFileName,
+			newObject:            func() runtime.Object { return &corev1.Service{} },
+			newList:              func() runtime.Object { return &corev1.ServiceList{} },
+		},
 	}
 }
 
-func (c *serviceClient) Reify(templateValues interface{}) ([]byte, error) {
-	result, err := reify.Reify(c.templateFileName, templateValues, c.globalTemplateValues)
-	if err != nil {
-		return nil, err
-	}
-	return result, nil
+func (c *serviceClient) WithContext(ctx context.Context) Client {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	return &clientCopy
 }
 
-func (c *serviceClient) Create(namespace string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
-	if err != nil {
-		return err
-	}
-
-	request := c.restClient.Post().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] create resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
-	if err != nil {
-		return err
-	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
-	}
-	return nil
+func (c *serviceClient) withInformers(informers *informer.Cache) Client {
+	clientCopy := *c
+	clientCopy.informers = informers
+	return &clientCopy
 }
 
-func (c *serviceClient) Delete(namespace, name string) error {
-	request := c.restClient.Delete().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name)
-
-	glog.Infof("[DEBUG] delete resource URL: %s", request.URL())
-
-	return request.Do().Error()
+func (c *serviceClient) Update(namespace string, name string, templateValues interface{}) error {
+	_, err := c.UpdateObject(namespace, name, templateValues)
+	return err
 }
 
-func (c *serviceClient) Update(namespace string, name string, templateValues interface{}) error {
-	resourceBody, err := c.Reify(templateValues)
+// UpdateObject replaces the service with the reified template, keeping the
+// cluster IP and node ports that the API server assigned to it. The API
+// server rejects a service whose cluster IP is cleared, and would assign
+// other node ports.
+func (c *serviceClient) UpdateObject(namespace string, name string, templateValues interface{}) (runtime.Object, error) {
+	resourceBody, err := c.body(name, templateValues)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	request := c.restClient.Put().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		Body(resourceBody)
-
-	glog.Infof("[DEBUG] update resource URL: %s", request.URL())
-
-	var statusCode int
-	err = request.Do().StatusCode(&statusCode).Error()
-
+	existing, err := c.Get(namespace, name)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if statusCode != http.StatusOK && statusCode != http.StatusCreated {
-		return fmt.Errorf("unexpected status code (%d)", statusCode)
+	resourceBody, err = withAssignedFields(resourceBody, existing.(*corev1.Service))
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return c.update(namespace, name, resourceBody)
 }
 
-func (c *serviceClient) Patch(namespace string, name string, data []byte) error {
-
-	request := c.restClient.Patch(types.JSONPatchType).
-		Resource(c.resourcePluralForm).
-		Namespace(namespace).
-		Name(name).
-		Body(data)
-
-	glog.Infof("[DEBUG] patch resource URL: %s", request.URL())
+func (c *serviceClient) IsEphemeral() bool {
+	return true
+}
 
-	return request.Do().Error()
+func (c *serviceClient) IsFailed(namespace string, name string) bool {
+	// A service never fails on its own account.
+	return false
 }
 
-func (c *serviceClient) Get(namespace, name string) (result runtime.Object, err error) {
-	result = &corev1.Service{}
-	opts := metav1.GetOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		Name(name).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(result)
+func (c *serviceClient) GetStatusState(obj runtime.Object) states.State {
+	state, _ := c.serviceStatus(obj)
+	return state
+}
 
-	return result, err
+func (c *serviceClient) GetStatusReason(obj runtime.Object) string {
+	_, reason := c.serviceStatus(obj)
+	return reason
 }
 
-func (c *serviceClient) List(namespace string, labels map[string]string) (result []metav1.Object, err error) {
-	list := &corev1.ServiceList{}
-	opts := metav1.ListOptions{}
-	err = c.restClient.Get().
-		Namespace(namespace).
-		Resource(c.resourcePluralForm).
-		VersionedParams(&opts, scheme.ParameterCodec).
-		Do().
-		Into(list)
+func (c *serviceClient) GetProgress(obj runtime.Object) string {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		panic("object was not a *corev1.Service")
+	}
 
+	endpoints, err := c.endpoints(service)
 	if err != nil {
-		return []metav1.Object{}, err
+		return ""
 	}
 
-	for _, item := range list.Items {
-		// We need a copy of the item here because item has function scope whereas the copy below has a local scope.
-		// Ex: When we iterate through items, the result list will only contain multiple copies of the last item in the list.
-		serviceCopy := item
-		result = append(result, &serviceCopy)
+	ready := 0
+	for _, subset := range endpoints.Subsets {
+		ready += len(subset.Addresses)
 	}
-
-	return
+	return fmt.Sprintf("%d ready endpoints", ready)
 }
 
-func (c *serviceClient) IsEphemeral() bool {
-	return true
+// endpoints returns the endpoints of the supplied service, which have the
+// same name as the service. They are served from shared informers if the
+// client has them.
+func (c *serviceClient) endpoints(service *corev1.Service) (*corev1.Endpoints, error) {
+	if c.informers != nil {
+		lw := cache.NewListWatchFromClient(c.restClient, "endpoints", service.Namespace, fields.Everything())
+		gvr := corev1.SchemeGroupVersion.WithResource("endpoints")
+		sharedInformer := c.informers.Informer(gvr.String()+"/"+service.Namespace, lw, &corev1.Endpoints{})
+		if sharedInformer.HasSynced() {
+			obj, exists, err := informer.Get(sharedInformer, service.Namespace, service.Name)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				// E.g. a service without a selector.
+				return nil, apierrors.NewNotFound(gvr.GroupResource(), service.Name)
+			}
+			return obj.(*corev1.Endpoints), nil
+		}
+	}
+
+	endpoints := &corev1.Endpoints{}
+	err := c.restClient.Get().
+		Context(c.ctx).
+		Namespace(service.Namespace).
+		Resource("endpoints").
+		Name(service.Name).
+		VersionedParams(&metav1.GetOptions{}, scheme.ParameterCodec).
+		Do().
+		Into(endpoints)
+	return endpoints, err
 }
 
-func (c *serviceClient) Plural() string {
-	return c.resourcePluralForm
+// serviceStatus returns the state of the supplied service and, if it is
+// pending, the reason why. Completed and Failed don't make sense for this
+// type.
+func (c *serviceClient) serviceStatus(obj runtime.Object) (states.State, string) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		panic("object was not a *corev1.Service")
+	}
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) == 0 {
+		return states.Pending, "waiting for a load balancer address"
+	}
+	return states.Running, ""
 }
 
-func (c *serviceClient) IsFailed(namespace string, name string) bool {
-	return false
+// withAssignedFields returns the supplied service body, with the fields that
+// the template leaves to the API server copied from the existing service.
+func withAssignedFields(body []byte, existing *corev1.Service) ([]byte, error) {
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(body); err != nil {
+		return nil, err
+	}
+	spec, ok := obj.Object["spec"].(map[string]interface{})
+	if !ok {
+		return body, nil
+	}
+	serviceType, _ := spec["type"].(string)
+
+	if _, ok := spec["clusterIP"]; !ok && existing.Spec.ClusterIP != "" && serviceType != string(corev1.ServiceTypeExternalName) {
+		spec["clusterIP"] = existing.Spec.ClusterIP
+	}
+	if serviceType != string(corev1.ServiceTypeNodePort) && serviceType != string(corev1.ServiceTypeLoadBalancer) {
+		return obj.MarshalJSON()
+	}
+	if _, ok := spec["healthCheckNodePort"]; !ok && existing.Spec.HealthCheckNodePort != 0 && spec["externalTrafficPolicy"] == string(corev1.ServiceExternalTrafficPolicyTypeLocal) {
+		spec["healthCheckNodePort"] = int64(existing.Spec.HealthCheckNodePort)
+	}
+	ports, _ := spec["ports"].([]interface{})
+	for _, item := range ports {
+		port, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := port["nodePort"]; ok {
+			continue
+		}
+		for _, existingPort := range existing.Spec.Ports {
+			if existingPort.NodePort != 0 && samePort(port, existingPort) {
+				port["nodePort"] = int64(existingPort.NodePort)
+			}
+		}
+	}
+	return obj.MarshalJSON()
 }
 
-func (c *serviceClient) GetStatusState(obj runtime.Object) states.State {
-	// TODO(CD): Detect Pending and Failed. Completed doesn't make sense for this type.
-	return states.Running
+// samePort returns true if the supplied templated port is the existing
+// port: the ports of a service are told apart by their names, or by their
+// numbers if unnamed.
+func samePort(port map[string]interface{}, existing corev1.ServicePort) bool {
+	if name, _ := port["name"].(string); name != "" || existing.Name != "" {
+		return name == existing.Name
+	}
+	return fmt.Sprint(port["port"]) == fmt.Sprint(existing.Port)
 }
@@ -0,0 +1,192 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+func TestServiceStatusState(t *testing.T) {
+	loadBalancer := corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "10.0.0.1"}}}
+	tests := map[string]struct {
+		serviceType  corev1.ServiceType
+		loadBalancer corev1.LoadBalancerStatus
+		expected     states.State
+		reason       string
+	}{
+		"cluster IP": {
+			serviceType: corev1.ServiceTypeClusterIP,
+			expected:    states.Running,
+		},
+		"node port": {
+			serviceType: corev1.ServiceTypeNodePort,
+			expected:    states.Running,
+		},
+		"load balancer without address": {
+			serviceType: corev1.ServiceTypeLoadBalancer,
+			expected:    states.Pending,
+			reason:      "waiting for a load balancer address",
+		},
+		"load balancer": {
+			serviceType:  corev1.ServiceTypeLoadBalancer,
+			loadBalancer: loadBalancer,
+			expected:     states.Running,
+		},
+	}
+
+	c := &serviceClient{}
+	for name, tc := range tests {
+		service := &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "service1", Namespace: "namespace1"},
+			Spec:       corev1.ServiceSpec{Type: tc.serviceType},
+			Status:     corev1.ServiceStatus{LoadBalancer: tc.loadBalancer},
+		}
+		assert.Equal(t, tc.expected, c.GetStatusState(service), name)
+		assert.Equal(t, tc.reason, c.GetStatusReason(service), name)
+	}
+}
+
+func TestServiceProgressInformers(t *testing.T) {
+	var gets int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/api/v1/namespaces/namespace1/endpoints" && r.URL.Query().Get("watch") == "true":
+			// Watch without events until the informer stops.
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case r.URL.Path == "/api/v1/namespaces/namespace1/endpoints":
+			json.NewEncoder(w).Encode(&corev1.EndpointsList{
+				TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "EndpointsList"},
+				ListMeta: metav1.ListMeta{ResourceVersion: "1"},
+				Items: []corev1.Endpoints{{
+					ObjectMeta: metav1.ObjectMeta{Name: "service1", Namespace: "namespace1"},
+					Subsets: []corev1.EndpointSubset{
+						{Addresses: []corev1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}}},
+					},
+				}},
+			})
+		default:
+			atomic.AddInt32(&gets, 1)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	clientSet, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	require.NoError(t, err)
+	informers := informer.NewCache(0, stopCh)
+	c := Unwrap(NewCachedClient(NewServiceClient(GlobalTemplateValues{}, clientSet, "service.yaml"), informers)).(*serviceClient)
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service1", Namespace: "namespace1"}}
+
+	// Before the informer has synced, the endpoints are got from the API
+	// server.
+	c.GetProgress(service)
+	require.True(t, cache.WaitForCacheSync(stopCh, func() bool {
+		gvr := corev1.SchemeGroupVersion.WithResource("endpoints")
+		return informers.Informer(gvr.String()+"/namespace1", nil, nil).HasSynced()
+	}))
+	before := atomic.LoadInt32(&gets)
+
+	assert.Equal(t, "2 ready endpoints", c.GetProgress(service))
+	// A service without endpoints, e.g. without a selector.
+	other := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "service2", Namespace: "namespace1"}}
+	assert.Equal(t, "", c.GetProgress(other))
+	assert.Equal(t, before, atomic.LoadInt32(&gets))
+}
+
+func TestWithAssignedFields(t *testing.T) {
+	existing := &corev1.Service{
+		Spec: corev1.ServiceSpec{
+			ClusterIP:           "10.0.0.10",
+			HealthCheckNodePort: 31000,
+			Ports: []corev1.ServicePort{
+				{Name: "http", Port: 80, NodePort: 30080},
+				{Name: "https", Port: 443, NodePort: 30443},
+			},
+		},
+	}
+	unnamed := existing.DeepCopy()
+	unnamed.Spec.Ports = []corev1.ServicePort{{Port: 80, NodePort: 30080}}
+
+	tests := map[string]struct {
+		existing *corev1.Service
+		body     string
+		expected string
+	}{
+		"cluster IP": {
+			existing: existing,
+			body:     `{"kind":"Service","spec":{"ports":[{"name":"http","port":80}]}}`,
+			expected: `{"kind":"Service","spec":{"clusterIP":"10.0.0.10","ports":[{"name":"http","port":80}]}}`,
+		},
+		"templated cluster IP": {
+			existing: existing,
+			body:     `{"kind":"Service","spec":{"clusterIP":"None"}}`,
+			expected: `{"kind":"Service","spec":{"clusterIP":"None"}}`,
+		},
+		"external name": {
+			existing: existing,
+			body:     `{"kind":"Service","spec":{"externalName":"example.com","type":"ExternalName"}}`,
+			expected: `{"kind":"Service","spec":{"externalName":"example.com","type":"ExternalName"}}`,
+		},
+		"node ports by name": {
+			existing: existing,
+			body:     `{"kind":"Service","spec":{"ports":[{"name":"https","port":8443},{"name":"admin","port":9000}],"type":"NodePort"}}`,
+			expected: `{"kind":"Service","spec":{"clusterIP":"10.0.0.10","ports":[{"name":"https","nodePort":30443,"port":8443},{"name":"admin","port":9000}],"type":"NodePort"}}`,
+		},
+		"node ports by number": {
+			existing: unnamed,
+			body:     `{"kind":"Service","spec":{"ports":[{"port":80}],"type":"NodePort"}}`,
+			expected: `{"kind":"Service","spec":{"clusterIP":"10.0.0.10","ports":[{"nodePort":30080,"port":80}],"type":"NodePort"}}`,
+		},
+		"health check node port": {
+			existing: existing,
+			body:     `{"kind":"Service","spec":{"clusterIP":"10.0.0.10","externalTrafficPolicy":"Local","type":"LoadBalancer"}}`,
+			expected: `{"kind":"Service","spec":{"clusterIP":"10.0.0.10","externalTrafficPolicy":"Local","healthCheckNodePort":31000,"type":"LoadBalancer"}}`,
+		},
+		"no spec": {
+			existing: existing,
+			body:     `{"kind":"Service"}`,
+			expected: `{"kind":"Service"}`,
+		},
+	}
+	for name, tc := range tests {
+		body, err := withAssignedFields([]byte(tc.body), tc.existing)
+		require.NoError(t, err, name)
+		assert.JSONEq(t, tc.expected, string(body), name)
+	}
+}
@@ -0,0 +1,160 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/informer"
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// statefulSetGroupVersions are the group versions serving stateful sets, in
+// order of preference.
+var statefulSetGroupVersions = []schema.GroupVersion{
+	{Group: "apps", Version: "v1"},
+	{Group: "apps", Version: "v1beta2"},
+	{Group: "apps", Version: "v1beta1"},
+}
+
+type statefulSetClient struct {
+	*unstructuredClient
+	pods *podLister
+}
+
+// NewStatefulSetClient returns a new stateful set client for the most
+// preferred API version served by the cluster. Stateful set templates of
+// another API version are converted to it. An error is returned if the API
+// version cannot be discovered. Deleting a stateful set deletes its pods, and
+// retains the persistent volume claims created from its volume claim
+// templates.
+func NewStatefulSetClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, templateFileName string) (Client, error) {
+	clientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	groupVersion, err := preferredGroupVersion(clientSet.Discovery(), "statefulsets", statefulSetGroupVersions)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newUnstructuredClient(globalTemplateValues, config, UnstructuredResource{
+		GroupVersionResource: groupVersion.WithResource("statefulsets"),
+		Kind:                 "StatefulSet",
+	}, templateFileName)
+	if err != nil {
+		return nil, err
+	}
+	c.apiVersion = groupVersion
+	return &statefulSetClient{unstructuredClient: c, pods: newPodLister(clientSet)}, nil
+}
+
+func (c *statefulSetClient) WithContext(ctx context.Context) Client {
+	return &statefulSetClient{unstructuredClient: c.withContext(ctx), pods: c.pods.withContext(ctx)}
+}
+
+func (c *statefulSetClient) withInformers(informers *informer.Cache) Client {
+	return &statefulSetClient{unstructuredClient: c.unstructuredClient, pods: c.pods.withInformers(informers)}
+}
+
+func (c *statefulSetClient) IsFailed(namespace string, name string) bool {
+	return isFailed(c, namespace, name)
+}
+
+// statefulSet returns the supplied stateful set, of any API version, as an
+// apps/v1beta2 stateful set.
+func statefulSet(obj runtime.Object) *appsv1beta2.StatefulSet {
+	set := &appsv1beta2.StatefulSet{}
+	if err := fromUnstructured(obj, set); err != nil {
+		panic(fmt.Sprintf("object was not a stateful set: %v", err))
+	}
+	return set
+}
+
+func (c *statefulSetClient) isFailed(set *appsv1beta2.StatefulSet) bool {
+	// A stateful set has no failure condition of its own. Instead we inspect
+	// whether the ordinal pods controlled by the stateful set are healthy.
+	pods, err := c.pods.list(set.Namespace, set.Spec.Selector, set.UID)
+	if err != nil {
+		return false
+	}
+
+	for _, pod := range pods {
+		if state, _ := podState(pod); state == states.Failed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isPending returns true if the stateful set has not been rolled out to all
+// of its replicas yet.
+func (c *statefulSetClient) isPending(set *appsv1beta2.StatefulSet) bool {
+	replicas := int32(1)
+	if set.Spec.Replicas != nil {
+		replicas = *set.Spec.Replicas
+	}
+
+	// The stateful set controller has not observed the latest spec yet.
+	if set.Status.ObservedGeneration < set.Generation {
+		return true
+	}
+	if set.Status.ReadyReplicas < replicas {
+		return true
+	}
+
+	// With the OnDelete strategy, pods are only updated once they are
+	// deleted, so the current and update revisions may legitimately differ.
+	if set.Spec.UpdateStrategy.Type == appsv1beta2.OnDeleteStatefulSetStrategyType {
+		return false
+	}
+	// With a partitioned rolling update, only the pods with an ordinal of at
+	// least the partition are updated.
+	if rollingUpdate := set.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+		updated := replicas - *rollingUpdate.Partition
+		if updated < 0 {
+			updated = 0
+		}
+		return set.Status.UpdatedReplicas < updated
+	}
+	// A rolling update is in progress.
+	if set.Status.UpdateRevision != "" && set.Status.CurrentRevision != set.Status.UpdateRevision {
+		return true
+	}
+	return set.Status.UpdatedReplicas < replicas
+}
+
+func (c *statefulSetClient) GetStatusState(obj runtime.Object) states.State {
+	set := statefulSet(obj)
+	if c.isFailed(set) {
+		return states.Failed
+	}
+	if c.isPending(set) {
+		return states.Pending
+	}
+	// Completed doesn't make sense for this type.
+	return states.Running
+}
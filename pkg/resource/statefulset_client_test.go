@@ -0,0 +1,108 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStatefulSetIsPending(t *testing.T) {
+	int32Ptr := func(i int32) *int32 { return &i }
+	rolledOut := appsv1beta2.StatefulSetStatus{
+		ObservedGeneration: 1,
+		ReadyReplicas:      3,
+		UpdatedReplicas:    3,
+		CurrentRevision:    "rev1",
+		UpdateRevision:     "rev1",
+	}
+	tests := map[string]struct {
+		strategy appsv1beta2.StatefulSetUpdateStrategy
+		status   func(status *appsv1beta2.StatefulSetStatus)
+		expected bool
+	}{
+		"rolled out": {
+			expected: false,
+		},
+		"generation not observed": {
+			status:   func(status *appsv1beta2.StatefulSetStatus) { status.ObservedGeneration = 0 },
+			expected: true,
+		},
+		"replicas not ready": {
+			status:   func(status *appsv1beta2.StatefulSetStatus) { status.ReadyReplicas = 2 },
+			expected: true,
+		},
+		"rolling update in progress": {
+			status: func(status *appsv1beta2.StatefulSetStatus) {
+				status.UpdateRevision = "rev2"
+				status.UpdatedReplicas = 1
+			},
+			expected: true,
+		},
+		"on delete with outdated pods": {
+			strategy: appsv1beta2.StatefulSetUpdateStrategy{Type: appsv1beta2.OnDeleteStatefulSetStrategyType},
+			status: func(status *appsv1beta2.StatefulSetStatus) {
+				status.UpdateRevision = "rev2"
+				status.UpdatedReplicas = 0
+			},
+			expected: false,
+		},
+		"partition updated": {
+			strategy: appsv1beta2.StatefulSetUpdateStrategy{
+				Type:          appsv1beta2.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &appsv1beta2.RollingUpdateStatefulSetStrategy{Partition: int32Ptr(2)},
+			},
+			status: func(status *appsv1beta2.StatefulSetStatus) {
+				status.UpdateRevision = "rev2"
+				status.UpdatedReplicas = 1
+			},
+			expected: false,
+		},
+		"partition updating": {
+			strategy: appsv1beta2.StatefulSetUpdateStrategy{
+				Type:          appsv1beta2.RollingUpdateStatefulSetStrategyType,
+				RollingUpdate: &appsv1beta2.RollingUpdateStatefulSetStrategy{Partition: int32Ptr(1)},
+			},
+			status: func(status *appsv1beta2.StatefulSetStatus) {
+				status.UpdateRevision = "rev2"
+				status.UpdatedReplicas = 1
+			},
+			expected: true,
+		},
+	}
+
+	c := &statefulSetClient{}
+	for name, tc := range tests {
+		set := &appsv1beta2.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Generation: 1},
+			Spec: appsv1beta2.StatefulSetSpec{
+				Replicas:       int32Ptr(3),
+				UpdateStrategy: tc.strategy,
+			},
+			Status: rolledOut,
+		}
+		if tc.status != nil {
+			tc.status(&set.Status)
+		}
+		assert.Equal(t, tc.expected, c.isPending(set), name)
+	}
+}
@@ -0,0 +1,172 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	unstructuredconv "k8s.io/apimachinery/pkg/conversion/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+// StatusFunc returns the current status of an unstructured resource.
+type StatusFunc func(obj *unstructured.Unstructured) states.State
+
+// UnstructuredResource describes a kind of resource managed by an
+// unstructured client.
+type UnstructuredResource struct {
+	// GroupVersionResource identifies the resource, e.g. apps/v1 statefulsets.
+	GroupVersionResource schema.GroupVersionResource
+	// Kind is the kind of the resource, e.g. StatefulSet.
+	Kind string
+	// Ephemeral resources can be safely deleted and recreated.
+	Ephemeral bool
+	// StatusFunc evaluates the status of the resource. If nil, the resource
+	// is always considered running.
+	StatusFunc StatusFunc
+}
+
+type unstructuredClient struct {
+	baseClient
+	resource UnstructuredResource
+}
+
+// NewUnstructuredClient returns a new client for resources of any group,
+// version and kind, including other custom resources. Objects are handled
+// as unstructured data, so no Go types are required for the resource.
+func NewUnstructuredClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, resource UnstructuredResource, templateFileName string) (Client, error) {
+	c, err := newUnstructuredClient(globalTemplateValues, config, resource, templateFileName)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newUnstructuredClient returns a new unstructured client. Typed clients
+// embed it and add the evaluation of their status.
+func newUnstructuredClient(globalTemplateValues GlobalTemplateValues, config *rest.Config, resource UnstructuredResource, templateFileName string) (*unstructuredClient, error) {
+	gv := resource.GroupVersionResource.GroupVersion()
+
+	configCopy := *config
+	configCopy.ContentConfig = dynamic.ContentConfig()
+	configCopy.GroupVersion = &gv
+	configCopy.APIPath = "/apis"
+	if gv.Group == "" {
+		configCopy.APIPath = "/api"
+	}
+
+	restClient, err := rest.RESTClientFor(&configCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unstructuredClient{
+		baseClient: baseClient{
+			globalTemplateValues: globalTemplateValues,
+			restClient:           restClient,
+			resourcePluralForm:   resource.GroupVersionResource.Resource,
+			templateFileName:     templateFileName,
+			newObject:            func() runtime.Object { return &unstructured.Unstructured{} },
+			newList:              func() runtime.Object { return &unstructured.UnstructuredList{} },
+			deletePropagation:    metav1.DeletePropagationForeground,
+		},
+		resource: resource,
+	}, nil
+}
+
+func (c *unstructuredClient) WithContext(ctx context.Context) Client {
+	return c.withContext(ctx)
+}
+
+// withContext is like WithContext, for the typed clients that embed the
+// unstructured client.
+func (c *unstructuredClient) withContext(ctx context.Context) *unstructuredClient {
+	clientCopy := *c
+	clientCopy.ctx = ctx
+	return &clientCopy
+}
+
+func (c *unstructuredClient) IsEphemeral() bool {
+	return c.resource.Ephemeral
+}
+
+func (c *unstructuredClient) IsFailed(namespace string, name string) bool {
+	return isFailed(c, namespace, name)
+}
+
+func (c *unstructuredClient) GetStatusState(obj runtime.Object) states.State {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		panic(fmt.Sprintf("object was not a *unstructured.Unstructured (%s)", c.resource.Kind))
+	}
+	if c.resource.StatusFunc == nil {
+		return states.Running
+	}
+	return c.resource.StatusFunc(u)
+}
+
+// preferredGroupVersion returns the first of the supplied group versions that
+// serves the supplied resource.
+func preferredGroupVersion(client discovery.DiscoveryInterface, resource string, groupVersions []schema.GroupVersion) (schema.GroupVersion, error) {
+	for _, groupVersion := range groupVersions {
+		resources, err := client.ServerResourcesForGroupVersion(groupVersion.String())
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return schema.GroupVersion{}, fmt.Errorf("failed to discover the API version of %s: %v", resource, err)
+		}
+		for _, served := range resources.APIResources {
+			if served.Name == resource {
+				return groupVersion, nil
+			}
+		}
+	}
+	return schema.GroupVersion{}, fmt.Errorf("no API version of %s is served", resource)
+}
+
+// fromUnstructured converts an object returned by an unstructured client, or
+// served from its informer, into the supplied typed object.
+func fromUnstructured(obj runtime.Object, typed interface{}) error {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("object was not a *unstructured.Unstructured")
+	}
+	return unstructuredconv.DefaultConverter.FromUnstructured(u.Object, typed)
+}
+
+// isFailed returns true if the object with the supplied name is in a failed
+// state according to the supplied client.
+func isFailed(c Client, namespace string, name string) bool {
+	obj, err := c.Get(namespace, name)
+	if err != nil {
+		return false
+	}
+	return c.GetStatusState(obj) == states.Failed
+}
@@ -0,0 +1,230 @@
+//
+// Copyright (c) 2019 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// SPDX-License-Identifier: EPL-2.0
+//
+
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/rest"
+
+	"github.com/intel/crd-reconciler-for-kubernetes/pkg/states"
+)
+
+func TestUnstructuredClientPaths(t *testing.T) {
+	tests := map[string]struct {
+		gvr      schema.GroupVersionResource
+		kind     string
+		expected string
+	}{
+		"core": {
+			gvr:      schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			kind:     "ConfigMap",
+			expected: "/api/v1/namespaces/namespace1/configmaps/object1",
+		},
+		"group": {
+			gvr:      schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"},
+			kind:     "Widget",
+			expected: "/apis/example.com/v1/namespaces/namespace1/widgets/object1",
+		},
+	}
+	for name, tc := range tests {
+		var path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"apiVersion":"` + tc.gvr.GroupVersion().String() + `","kind":"` + tc.kind + `","metadata":{"name":"object1","namespace":"namespace1"}}`))
+		}))
+
+		c, err := NewUnstructuredClient(GlobalTemplateValues{}, &rest.Config{Host: server.URL}, UnstructuredResource{
+			GroupVersionResource: tc.gvr,
+			Kind:                 tc.kind,
+			Ephemeral:            true,
+		}, "object.yaml")
+		require.NoError(t, err, name)
+		assert.Equal(t, tc.gvr.Resource, c.Plural(), name)
+		assert.True(t, c.IsEphemeral(), name)
+		assert.Equal(t, tc.gvr, c.(CacheableClient).GroupVersionResource(), name)
+
+		obj, err := c.Get("namespace1", "object1")
+		server.Close()
+		require.NoError(t, err, name)
+		assert.Equal(t, tc.expected, path, name)
+		u, ok := obj.(*unstructured.Unstructured)
+		require.True(t, ok, name)
+		assert.Equal(t, tc.kind, u.GetKind(), name)
+		assert.Equal(t, "object1", u.GetName(), name)
+	}
+}
+
+func TestUnstructuredClientStatusFunc(t *testing.T) {
+	phase := func(obj *unstructured.Unstructured) states.State {
+		status, _ := obj.Object["status"].(map[string]interface{})
+		if status["phase"] == "Broken" {
+			return states.Failed
+		}
+		return states.Pending
+	}
+	tests := map[string]struct {
+		statusFunc StatusFunc
+		phase      string
+		expected   states.State
+	}{
+		"no status function": {
+			phase:    "Broken",
+			expected: states.Running,
+		},
+		"status function": {
+			statusFunc: phase,
+			phase:      "Starting",
+			expected:   states.Pending,
+		},
+		"status function failed": {
+			statusFunc: phase,
+			phase:      "Broken",
+			expected:   states.Failed,
+		},
+	}
+	for name, tc := range tests {
+		c, err := NewUnstructuredClient(GlobalTemplateValues{}, &rest.Config{Host: "localhost"}, UnstructuredResource{
+			GroupVersionResource: schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"},
+			Kind:                 "Widget",
+			StatusFunc:           tc.statusFunc,
+		}, "widget.yaml")
+		require.NoError(t, err, name)
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "example.com/v1",
+			"kind":       "Widget",
+			"status":     map[string]interface{}{"phase": tc.phase},
+		}}
+		assert.Equal(t, tc.expected, c.GetStatusState(obj), name)
+	}
+}
+
+// fakeDiscovery serves the supplied resources in the supplied group versions,
+// and fails with the supplied error for the other ones.
+type fakeDiscovery struct {
+	discovery.DiscoveryInterface
+	groupVersions []string
+	resources     []string
+	err           error
+}
+
+func (d *fakeDiscovery) ServerResourcesForGroupVersion(groupVersion string) (*metav1.APIResourceList, error) {
+	for _, served := range d.groupVersions {
+		if served == groupVersion {
+			list := &metav1.APIResourceList{GroupVersion: groupVersion}
+			for _, resource := range d.resources {
+				list.APIResources = append(list.APIResources, metav1.APIResource{Name: resource})
+			}
+			return list, nil
+		}
+	}
+	return nil, d.err
+}
+
+// discoveryServer serves the supplied resources in the supplied group
+// version, and no other group versions.
+func discoveryServer(groupVersion string, resources ...string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apis/"+groupVersion {
+			http.NotFound(w, r)
+			return
+		}
+		list := &metav1.APIResourceList{GroupVersion: groupVersion}
+		for _, resource := range resources {
+			list.APIResources = append(list.APIResources, metav1.APIResource{Name: resource, Namespaced: true})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}))
+}
+
+func TestPreferredGroupVersion(t *testing.T) {
+	notFound := apierrors.NewNotFound(schema.GroupResource{}, "")
+	tests := map[string]struct {
+		resource      string
+		groupVersions []schema.GroupVersion
+		discovery     *fakeDiscovery
+		expected      string
+		err           bool
+	}{
+		"most preferred": {
+			resource:      "deployments",
+			groupVersions: deploymentGroupVersions,
+			discovery:     &fakeDiscovery{groupVersions: []string{"apps/v1beta2", "extensions/v1beta1"}, resources: []string{"deployments"}, err: notFound},
+			expected:      "apps/v1beta2",
+		},
+		"extensions only": {
+			resource:      "deployments",
+			groupVersions: deploymentGroupVersions,
+			discovery:     &fakeDiscovery{groupVersions: []string{"extensions/v1beta1"}, resources: []string{"deployments"}, err: notFound},
+			expected:      "extensions/v1beta1",
+		},
+		"extensions daemon sets": {
+			resource:      "daemonsets",
+			groupVersions: daemonSetGroupVersions,
+			discovery:     &fakeDiscovery{groupVersions: []string{"apps/v1beta1", "extensions/v1beta1"}, resources: []string{"daemonsets"}, err: notFound},
+			expected:      "extensions/v1beta1",
+		},
+		"apps/v1beta1 stateful sets": {
+			resource:      "statefulsets",
+			groupVersions: statefulSetGroupVersions,
+			discovery:     &fakeDiscovery{groupVersions: []string{"apps/v1beta1"}, resources: []string{"statefulsets"}, err: notFound},
+			expected:      "apps/v1beta1",
+		},
+		"group version without the resource": {
+			resource:      "daemonsets",
+			groupVersions: daemonSetGroupVersions,
+			discovery:     &fakeDiscovery{groupVersions: []string{"apps/v1", "extensions/v1beta1"}, resources: []string{"deployments"}, err: notFound},
+			err:           true,
+		},
+		"not served": {
+			resource:      "deployments",
+			groupVersions: deploymentGroupVersions,
+			discovery:     &fakeDiscovery{err: notFound},
+			err:           true,
+		},
+		"discovery failure": {
+			resource:      "deployments",
+			groupVersions: deploymentGroupVersions,
+			discovery:     &fakeDiscovery{groupVersions: []string{"extensions/v1beta1"}, resources: []string{"deployments"}, err: fmt.Errorf("connection refused")},
+			err:           true,
+		},
+	}
+	for name, tc := range tests {
+		groupVersion, err := preferredGroupVersion(tc.discovery, tc.resource, tc.groupVersions)
+		if tc.err {
+			assert.Error(t, err, name)
+			continue
+		}
+		assert.NoError(t, err, name)
+		assert.Equal(t, tc.expected, groupVersion.String(), name)
+	}
+}